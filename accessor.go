@@ -5,110 +5,568 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 const spanRangeIndicator = ':'
 
+// the seperator of a sub-selection is put between these
+const subSeperatorOpen, subSeperatorClose = '[', ']'
+
 // Access returns the through the span selected
 // items from a given slice.
 // =0 indicates that there is no bound -> all preceeding/following
 // <0 [1 2 3 4][-1] == -4 == [1 2 3 4][4]
 // >0 [1 2 3 4][1] == 1
+// A step other than 1 selects every step'th item only
+// and a negative one goes from left to right backwards.
 func access[T any](a span, items []T) []T {
-	if a.left > len(items) {
-		return []T{}
+	if a.step == 0 || a.step == 1 {
+		lo, hi := bounds(a, len(items))
+		return items[lo:hi]
+	}
+
+	idxs := indices(a, len(items))
+	result := make([]T, len(idxs))
+	for i, idx := range idxs {
+		result[i] = items[idx]
+	}
+	return result
+}
+
+// indices returns the 0-based indices of the items
+// a span selects from a slice of n items in order.
+func indices(a span, n int) []int {
+	step := a.step
+	if step == 0 {
+		step = 1
+	}
+
+	var result []int
+	if step > 0 {
+		lo, hi := bounds(a, n)
+		for i := lo; i < hi; i += step {
+			result = append(result, i)
+		}
+		return result
+	}
+
+	// going backwards the left is the upper bound
+	// so the bounds are the ones of the mirrored span
+	lo, hi := bounds(span{left: a.right, right: a.left}, n)
+	for i := hi - 1; i >= lo; i += step {
+		result = append(result, i)
 	}
+	return result
+}
 
-	if a.right > len(items) {
-		a.right = len(items)
+// bounds returns the 0-based and right exclusive bounds
+// of the items a span selects from a slice of n items.
+func bounds(a span, n int) (int, int) {
+	if a.left > n {
+		return n, n
+	}
+
+	if a.right > n {
+		a.right = n
 	}
 
 	if a.left < 0 {
-		a.left = (len(items) + a.left) + 1
+		a.left = (n + a.left) + 1
 		if a.left < 0 {
 			a.left = 0
 		}
 	}
 	if a.right < 0 {
-		a.right = (len(items) + a.right) + 1
+		a.right = (n + a.right) + 1
 		if a.right <= 0 {
-			return []T{}
+			return 0, 0
 		}
 	}
 
-	switch {
-	case a.left == 0 && a.right == 0:
-		return items
-	case a.left == 0 && a.right != 0:
-		return items[:a.right]
-	case a.left != 0 && a.right == 0:
-		return items[a.left-1:]
-	case a.left != 0 && a.right != 0:
-		return items[a.left-1 : a.right]
+	lo, hi := 0, n
+	if a.left != 0 {
+		lo = a.left - 1
+	}
+	if a.right != 0 {
+		hi = a.right
+	}
+
+	// spans with bounds of different signs can
+	// only be checked when the length is known
+	if lo > hi {
+		return lo, lo
+	}
+
+	return lo, hi
+}
+
+// selectFields returns the fields selected by the spans one after
+// another. The fields selected by a span with a sub-selection are
+// split again and only the selected sub-fields are returned instead.
+// Of the fields selected by a span with a character selection only
+// the selected characters are returned.
+func selectFields(spans []span, r record) []column {
+	result := make([]column, 0, len(r.fields))
+	for spanIdx, s := range spans {
+		var splitter StringSplitter
+		if s.sub != nil {
+			splitter = cutterToSplitter(cutterFromSeperator(s.subSep))
+		}
+
+		for _, idx := range indices(s, len(r.fields)) {
+			switch {
+			case s.sub != nil:
+				for _, sub := range selectFields([]span{*s.sub}, splitter(r.fields[idx])) {
+					sub.idx, sub.span = idx, spanIdx
+					result = append(result, r.within(idx, sub))
+				}
+			case s.chars != nil:
+				chars := runeSplitter(r.fields[idx])
+				c := column{value: strings.Join(access(*s.chars, chars.fields), ""), idx: idx, span: spanIdx, start: -1, end: -1}
+				// only characters next to each other are a part of the field
+				if lo, hi := bounds(*s.chars, len(chars.fields)); (s.chars.step == 0 || s.chars.step == 1) && lo < hi {
+					c.start, c.end = chars.starts[lo], chars.ends[hi-1]
+				}
+				result = append(result, r.within(idx, c))
+			default:
+				result = append(result, r.column(idx, spanIdx))
+			}
+		}
+	}
+	return result
+}
+
+// column returns the field idx of the record as a
+// column selected by the span with the index spanIdx.
+func (r record) column(idx, spanIdx int) column {
+	return column{value: r.fields[idx], idx: idx, span: spanIdx, start: r.starts[idx], end: r.ends[idx]}
+}
+
+// within moves the offsets of a column, which are the ones
+// in the field idx it is a part of, into the record. A part
+// of a field whose text is not its value, like the one of an
+// unquoted field, is not a part of the record.
+func (r record) within(idx int, c column) column {
+	start := r.starts[idx]
+	if c.start < 0 || start < 0 || r.text[start:r.ends[idx]] != r.fields[idx] {
+		c.start, c.end = -1, -1
+		return c
+	}
+	c.start, c.end = start+c.start, start+c.end
+	return c
+}
+
+// complement returns the fields which are not selected
+// by any of the spans in the order they are in. These
+// are not selected by any span so their span is -1.
+func complement(spans []span, r record) []column {
+	selected := make([]bool, len(r.fields))
+	for _, s := range spans {
+		for _, i := range indices(s, len(r.fields)) {
+			selected[i] = true
+		}
+	}
+
+	result := make([]column, 0, len(r.fields))
+	for i := range r.fields {
+		if !selected[i] {
+			result = append(result, r.column(i, -1))
+		}
+	}
+	return result
+}
+
+// selectInOrder returns the fields selected by any of the spans in
+// the order they are in, each only once like cut does. The span of
+// a field is the first one which selects it.
+func selectInOrder(spans []span, r record) []column {
+	selectedBy := make([]int, len(r.fields))
+	for i := range selectedBy {
+		selectedBy[i] = -1
+	}
+	for spanIdx, s := range spans {
+		for _, i := range indices(s, len(r.fields)) {
+			if selectedBy[i] < 0 {
+				selectedBy[i] = spanIdx
+			}
+		}
+	}
+
+	result := make([]column, 0, len(r.fields))
+	for i := range r.fields {
+		if selectedBy[i] >= 0 {
+			result = append(result, r.column(i, selectedBy[i]))
+		}
+	}
+	return result
+}
+
+// unsatisfiedSpan returns the first span with a bound that refers
+// to a field which does not exist in a line of n fields.
+func unsatisfiedSpan(spans []span, n int) (span, bool) {
+	for _, s := range spans {
+		for _, bound := range []int{s.left, s.right} {
+			if bound > n || -bound > n {
+				return s, true
+			}
+		}
+	}
+	return span{}, false
+}
+
+// fillMissing selects the fields like selectFields, but as if the fields
+// referred to by the bounds of the spans existed, with the value being
+// their value. Fields missing at the end are put behind the existing
+// ones, while for negative bounds they are put in front of them. The
+// filled in fields are not part of the record.
+func fillMissing(spans []span, r record, value string) []column {
+	n := len(r.fields)
+	result := make([]column, 0, n)
+	for spanIdx, s := range spans {
+		front, back := 0, 0
+		for _, bound := range []int{s.left, s.right} {
+			if bound > n && bound-n > back {
+				back = bound - n
+			}
+			if -bound > n && -bound-n > front {
+				front = -bound - n
+			}
+		}
+
+		filled := newRecord(r.text, front+n+back)
+		for i := 0; i < front; i++ {
+			filled.add(value, -1, -1)
+		}
+		for i, field := range r.fields {
+			filled.add(field, r.starts[i], r.ends[i])
+		}
+		for i := 0; i < back; i++ {
+			filled.add(value, -1, -1)
+		}
+
+		// the bounds have to refer to the same fields as before
+		shift := func(bound int) int {
+			switch {
+			case bound > 0:
+				return bound + front
+			case bound < 0:
+				return bound - back
+			}
+			return bound
+		}
+		s.left, s.right = shift(s.left), shift(s.right)
+
+		for _, c := range selectFields([]span{s}, filled) {
+			// missing fields in front are counted from the end like the
+			// bound which selected them, so that their number is it
+			c.idx -= front
+			if c.idx < 0 {
+				c.idx -= n + 1
+			}
+			c.span = spanIdx
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// formatSpan returns the span as it is written in FIELDS.
+func formatSpan(s span) string {
+	bound := func(b int) string {
+		if b == 0 {
+			return ""
+		}
+		return strconv.Itoa(b)
+	}
+
+	if s.left == s.right && s.left != 0 && s.step == 0 {
+		return bound(s.left)
+	}
+	result := bound(s.left) + string(spanRangeIndicator) + bound(s.right)
+	if s.step != 0 {
+		result += string(spanRangeIndicator) + bound(s.step)
+	}
+	return result
+}
+
+// keepSeperators merges the columns of contiguous fields selected by
+// the same span into one column, whose value is the text of the record
+// from the start of the first to the end of the last field, so that
+// the seperators between them are kept as they were. Columns that do
+// not hold a whole field, like sub-fields, are never merged.
+func keepSeperators(r record, columns []column) []column {
+	isWhole := func(c column) bool {
+		return c.idx >= 0 && c.idx < len(r.fields) && c.start >= 0 &&
+			c.start == r.starts[c.idx] && c.end == r.ends[c.idx]
+	}
+
+	result := make([]column, 0, len(columns))
+	// index of the last field merged into the last column
+	lastIdx := -1
+	for _, c := range columns {
+		if n := len(result); n != 0 && lastIdx >= 0 && c.idx == lastIdx+1 &&
+			c.span == result[n-1].span && isWhole(c) {
+			result[n-1].value = r.text[result[n-1].start:c.end]
+			result[n-1].end = c.end
+			lastIdx = c.idx
+			continue
+		}
+
+		lastIdx = -1
+		if isWhole(c) {
+			lastIdx = c.idx
+		}
+		result = append(result, c)
 	}
+	return result
+}
 
-	panic("Should never happen")
+// locateColumns sets the line number of the columns and turns
+// their byte offsets in the record into the ones in runes.
+func locateColumns(lineNumber int, r record, columns []column) {
+	for i := range columns {
+		c := &columns[i]
+		c.line, c.runeStart, c.runeEnd = lineNumber, -1, -1
+		if c.start < 0 {
+			continue
+		}
+		c.runeStart = utf8.RuneCountInString(r.text[:c.start])
+		c.runeEnd = c.runeStart + utf8.RuneCountInString(r.text[c.start:c.end])
+	}
 }
 
 // flagToSpans returns the spans specified by the flag.
 // The flag argument can contain multiple spans seperated
-// by the specified seperator.
+// by the specified seperator. A bound which is not a number
+// is taken as the name of a field, see resolveSpans.
 func flagToSpans(flag string, sep string) ([]span, error) {
 	// empty means select all, this is the default for empty flag
 	if len(flag) == 0 {
 		return []span{{}}, nil
 	}
 
-	items := strings.Split(flag, sep)
+	items := splitOutsideBrackets(flag, sep)
 
 	result := make([]span, 0, 3)
 	for _, item := range items {
-		if len(item) == 0 {
-			return nil, errors.New("input seems to be seperated badly")
+		s, err := parseSpan(item)
+		if err != nil {
+			return nil, err
 		}
+		result = append(result, s)
+	}
 
-		var idx, lDigit, rDigit int
-		var err error
+	return result, nil
+}
+
+// parseSpan parses a single span of the FIELDS grammar.
+func parseSpan(item string) (span, error) {
+	if len(item) == 0 {
+		return span{}, errors.New("input seems to be seperated badly")
+	}
 
-		count := strings.Count(item, string(spanRangeIndicator))
-		if count > 1 {
-			return nil, fmt.Errorf("there are too many '%c' in the '%s'", spanRangeIndicator, item)
+	// a sub-selection like 3[/]-1 splits the selected fields again
+	// and a trailing one like 3[1:8] selects characters of them
+	var subSep string
+	var sub, chars *span
+	if open := strings.IndexByte(item, subSeperatorOpen); open >= 0 {
+		end := strings.IndexByte(item[open:], subSeperatorClose)
+		if end < 0 {
+			return span{}, fmt.Errorf("the '%c' in '%s' is never closed", subSeperatorOpen, item)
 		}
+		end += open
 
-		idx = strings.IndexRune(item, spanRangeIndicator)
+		inner := item[open+1 : end]
+		if len(inner) == 0 {
+			return span{}, fmt.Errorf("there is nothing between the brackets in '%s'", item)
+		}
 
-		if count == 0 {
-			lDigit, err = strconv.Atoi(item)
-			rDigit = lDigit
+		if end == len(item)-1 {
+			charSpan, err := parseSpan(inner)
 			if err != nil {
-				return nil, fmt.Errorf("the left part of '%s' does not seem to be an integer", item)
+				return span{}, err
+			}
+			if hasNames([]span{charSpan}) || charSpan.sub != nil || charSpan.chars != nil {
+				return span{}, fmt.Errorf("the characters in '%s' can only be selected by a plain range", item)
 			}
+			chars = &charSpan
 		} else {
-			if idx == 0 {
-				lDigit = 0
-			} else {
-				lDigit, err = strconv.Atoi(item[:idx])
-				if err != nil {
-					return nil, fmt.Errorf("the left part of '%s' does not seem to be an integer", item)
-				}
+			subSpan, err := parseSpan(item[end+1:])
+			if err != nil {
+				return span{}, err
 			}
+			if hasNames([]span{subSpan}) {
+				return span{}, fmt.Errorf("the sub-fields in '%s' cannot be selected by name", item)
+			}
+			subSep, sub = inner, &subSpan
+		}
 
-			if idx == len(item)-1 {
-				rDigit = 0
-			} else {
-				rDigit, err = strconv.Atoi(item[idx+1:])
-				if err != nil {
-					return nil, fmt.Errorf("the right part of '%s' does not seem to be an integer", item)
-				}
+		if open == 0 {
+			return span{}, fmt.Errorf("there is no field selected in '%s'", item)
+		}
+		item = item[:open]
+	}
+
+	var idx, lDigit, rDigit, step int
+	var lName, rName string
+	var err error
+
+	count := strings.Count(item, string(spanRangeIndicator))
+	if count > 2 {
+		return span{}, fmt.Errorf("there are too many '%c' in the '%s'", spanRangeIndicator, item)
+	}
+
+	idx = strings.IndexRune(item, spanRangeIndicator)
+
+	// the range without the step
+	rng := item
+	if count == 2 {
+		stepIdx := strings.LastIndexByte(item, spanRangeIndicator)
+		if stepIdx != len(item)-1 {
+			step, err = strconv.Atoi(item[stepIdx+1:])
+			if err != nil || step == 0 {
+				return span{}, fmt.Errorf("the step of '%s' does not seem to be a non-zero integer", item)
 			}
 		}
+		rng = item[:stepIdx]
+	}
 
-		// we can only check for the left <= right logic if both have the same sign
-		if ((lDigit > 0 && rDigit > 0) || (lDigit < 0 && rDigit < 0)) && lDigit > rDigit {
-			return nil, fmt.Errorf("the left number cannot be greater than the right number in '%s'", item)
+	if count == 0 {
+		lDigit, lName, err = parseBound(rng)
+		rDigit, rName = lDigit, lName
+		if err != nil {
+			return span{}, fmt.Errorf("the left part of '%s' does not seem to be an integer", item)
+		}
+	} else {
+		lDigit, lName, err = parseBound(rng[:idx])
+		if err != nil {
+			return span{}, fmt.Errorf("the left part of '%s' does not seem to be an integer", item)
+		}
+
+		rDigit, rName, err = parseBound(rng[idx+1:])
+		if err != nil {
+			return span{}, fmt.Errorf("the right part of '%s' does not seem to be an integer", item)
 		}
-		result = append(result, span{left: lDigit, right: rDigit})
+	}
+
+	// we can only check for the left <= right logic if both have the same sign
+	// and when going backwards the left has to be the greater one
+	if (lDigit > 0 && rDigit > 0) || (lDigit < 0 && rDigit < 0) {
+		if step >= 0 && lDigit > rDigit {
+			return span{}, fmt.Errorf("the left number cannot be greater than the right number in '%s'", item)
+		}
+		if step < 0 && lDigit < rDigit {
+			return span{}, fmt.Errorf("the left number cannot be smaller than the right number with a negative step in '%s'", item)
+		}
+	}
+
+	return span{left: lDigit, right: rDigit, step: step, leftName: lName, rightName: rName, subSep: subSep, sub: sub, chars: chars}, nil
+}
+
+// splitOutsideBrackets splits s like strings.Split but
+// ignores seperators between square brackets, so that the
+// seperator of a sub-selection can be anything.
+func splitOutsideBrackets(s string, sep string) []string {
+	var result []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == subSeperatorOpen:
+			if end := strings.IndexByte(s[i:], subSeperatorClose); end >= 0 {
+				i += end
+			}
+		case strings.HasPrefix(s[i:], sep):
+			result = append(result, s[start:i])
+			start = i + len(sep)
+			i = start - 1
+		}
+	}
+	return append(result, s[start:])
+}
+
+// parseBound parses one side of a span, which is either
+// empty, a number or the name of a field. Something that
+// starts like a number has to be one though.
+func parseBound(s string) (int, string, error) {
+	if len(s) == 0 {
+		return 0, "", nil
+	}
+
+	digits := strings.TrimPrefix(s, "-")
+	if len(digits) == 0 || !unicode.IsDigit(rune(digits[0])) {
+		return 0, s, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	return n, "", err
+}
+
+// hasNames reports whether any of the spans has a bound
+// that is given by name.
+func hasNames(spans []span) bool {
+	for _, s := range spans {
+		if len(s.leftName) != 0 || len(s.rightName) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSpans replaces the named bounds of the spans by the
+// 1-based index of the first field with that name.
+func resolveSpans(spans []span, names []string) ([]span, error) {
+	lookup := func(name string) (int, error) {
+		for i, n := range names {
+			if n == name {
+				return i + 1, nil
+			}
+		}
+
+		available := make([]string, 0, len(names))
+		for _, n := range names {
+			if len(n) != 0 {
+				available = append(available, "'"+n+"'")
+			}
+		}
+		if len(available) == 0 {
+			return 0, fmt.Errorf("there is no field named '%s' as no field has a name", name)
+		}
+		return 0, fmt.Errorf("there is no field named '%s', available are: %s", name, strings.Join(available, ", "))
+	}
+
+	result := make([]span, 0, len(spans))
+	for _, s := range spans {
+		var err error
+		if len(s.leftName) != 0 {
+			if s.left, err = lookup(s.leftName); err != nil {
+				return nil, err
+			}
+		}
+		if len(s.rightName) != 0 {
+			if s.right, err = lookup(s.rightName); err != nil {
+				return nil, err
+			}
+		}
+
+		if s.left > 0 && s.right > 0 && s.step >= 0 && s.left > s.right {
+			return nil, fmt.Errorf("the field '%s' comes after the field '%s'", nameOrIndex(s.leftName, s.left), nameOrIndex(s.rightName, s.right))
+		}
+		if s.left > 0 && s.right > 0 && s.step < 0 && s.left < s.right {
+			return nil, fmt.Errorf("the field '%s' comes before the field '%s'", nameOrIndex(s.leftName, s.left), nameOrIndex(s.rightName, s.right))
+		}
+
+		s.leftName, s.rightName = "", ""
+		result = append(result, s)
 	}
 
 	return result, nil
 }
+
+func nameOrIndex(name string, idx int) string {
+	if len(name) != 0 {
+		return name
+	}
+	return strconv.Itoa(idx)
+}
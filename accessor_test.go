@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -79,6 +81,163 @@ func TestAccess(t *testing.T) {
 	check(span{left: -2, right: 5}, "st")
 	check(span{left: -2, right: -1}, "st")
 	check(span{left: -100, right: 100}, "Test")
+
+	// bounds with different signs that cross each other
+	check(span{left: -1, right: 2}, "")
+	check(span{left: 4, right: -4}, "")
+
+	// steps
+	check(span{step: 1}, "Test")
+	check(span{step: 2}, "Ts")
+	check(span{left: 2, step: 2}, "et")
+	check(span{left: 1, right: 3, step: 2}, "Ts")
+	check(span{left: 2, right: -1, step: 3}, "e")
+	check(span{step: 100}, "T")
+	check(span{left: 5, step: 2}, "")
+
+	// negative steps go backwards from left to right
+	check(span{step: -1}, "tseT")
+	check(span{step: -2}, "te")
+	check(span{left: 3, step: -1}, "seT")
+	check(span{right: 2, step: -1}, "tse")
+	check(span{left: -2, right: 1, step: -1}, "seT")
+	check(span{left: 100, right: 3, step: -1}, "ts")
+	check(span{left: -5, step: -1}, "")
+	check(span{left: 2, right: 3, step: -1}, "")
+	check(span{left: 3, right: 5, step: -1}, "")
+}
+
+func joinColumns(columns []column, sep string) string {
+	return strings.Join(values(columns), sep)
+}
+
+// joinOffsets joins the offsets of the columns like joinColumns.
+func joinOffsets(columns []column, sep string) string {
+	offsets := make([]string, len(columns))
+	for i, c := range columns {
+		offsets[i] = fmt.Sprintf("%d:%d", c.start, c.end)
+	}
+	return strings.Join(offsets, sep)
+}
+
+// recordOf returns the record of the fields as if
+// they were cut from a line seperated by spaces.
+func recordOf(fields ...string) record {
+	r := newRecord(strings.Join(fields, " "), len(fields))
+	pos := 0
+	for _, field := range fields {
+		r.add(field, pos, pos+len(field))
+		pos += len(field) + 1
+	}
+	r.found = len(fields) > 1
+	return r
+}
+
+func TestSelectFields(t *testing.T) {
+	items := recordOf("T", "e", "s", "t")
+
+	check := func(spans []span, expected string) {
+		actual := joinColumns(selectFields(spans, items), "")
+		if actual != expected {
+			t.Errorf("%v: Expected (%s) Actual (%s)", spans, expected, actual)
+		}
+	}
+
+	check(nil, "")
+	check([]span{{}}, "Test")
+	check([]span{{left: 3, right: 3}, {left: 1, right: 1}}, "sT")
+	check([]span{{left: 1, right: 2}, {left: 1}}, "TeTest")
+	check([]span{{left: 5}, {left: -1, right: -1}}, "t")
+
+	// sub-fields
+	paths := recordOf("/usr/bin/env", "localhost:8080")
+	checkSub := func(flag string, expected string) {
+		spans, err := flagToSpans(flag, ",")
+		if err != nil {
+			t.Errorf("There should not be an error converting from (%s) but got (%v)", flag, err)
+			return
+		}
+
+		actual := joinColumns(selectFields(spans, paths), "|")
+		if actual != expected {
+			t.Errorf("%s: Expected (%s) Actual (%s)", flag, expected, actual)
+		}
+	}
+
+	checkSub("1[/]-1", "env")
+	checkSub("1[/]2:", "usr|bin|env")
+	checkSub("2[:]2", "8080")
+	checkSub("2[:]3", "")
+	checkSub(":[/]-1", "env|localhost:8080")
+	checkSub("1[/]-1,2[:]1", "env|localhost")
+	checkSub("1[/]-1[n]1", "e")
+
+	// characters
+	checkSub("1[1:4]", "/usr")
+	checkSub("1[-3:]", "env")
+	checkSub("2[::-1]", "0808:tsohlacol")
+	checkSub("2[100]", "")
+	checkSub(":[1]", "/|l")
+	checkSub("1[/]-1[2:],2[:]1[-4:]", "nv|host")
+
+	// invalid bytes are kept as they are
+	invalid := recordOf("a\xffb")
+	if actual := joinColumns(selectFields([]span{{left: 1, right: 1, chars: &span{left: 2, right: 2}}}, invalid), ""); actual != "\xff" {
+		t.Errorf("Expected (%q) Actual (%q)", "\xff", actual)
+	}
+
+	// columns remember the field they were selected from
+	spans, _ := flagToSpans("2,1[/]2:", ",")
+	idxs := []int{}
+	for _, c := range selectFields(spans, paths) {
+		idxs = append(idxs, c.idx)
+	}
+	if !reflect.DeepEqual(idxs, []int{1, 0, 0, 0}) {
+		t.Errorf("Expected the columns to be from (%v) but are from (%v)", []int{1, 0, 0, 0}, idxs)
+	}
+
+	// columns know where their text is in the record
+	checkOffsets := func(r record, flag string, expected string) {
+		spans, _ := flagToSpans(flag, ",")
+		if actual := joinOffsets(selectFields(spans, r), "|"); actual != expected {
+			t.Errorf("%s: Expected (%s) Actual (%s)", flag, expected, actual)
+		}
+	}
+
+	checkOffsets(paths, ":", "0:12|13:27")
+	checkOffsets(paths, "1[/]2:", "1:4|5:8|9:12")
+	checkOffsets(paths, "2[:]-1", "23:27")
+	checkOffsets(paths, "2[2:4]", "14:17")
+	checkOffsets(paths, "2[-1:2]", "-1:-1")
+	checkOffsets(paths, "2[::2]", "-1:-1")
+	checkOffsets(recordOf("x/x"), "1[/]2", "2:3")
+
+	// the parts of a field which is not its text are not in the record
+	quoted := cutterToSplitter(quotedCutterFromSeperator(","))(`"a/b",c`)
+	checkOffsets(quoted, "1,1[/]2,2", "0:5|-1:-1|6:7")
+}
+
+func TestComplement(t *testing.T) {
+	items := recordOf("T", "e", "s", "t")
+
+	check := func(spans []span, expected string) {
+		actual := joinColumns(complement(spans, items), "")
+		if actual != expected {
+			t.Errorf("%v: Expected (%s) Actual (%s)", spans, expected, actual)
+		}
+	}
+
+	check(nil, "Test")
+	check([]span{{}}, "")
+	check([]span{{left: 1, right: 1}}, "est")
+	check([]span{{left: -1, right: -1}}, "Tes")
+	check([]span{{left: 2, right: 2}, {left: -1, right: -1}}, "Ts")
+	check([]span{{left: 3, right: 3}, {left: 1, right: 1}}, "et")
+	check([]span{{left: 1, right: 2}, {left: 2, right: 3}}, "t")
+	check([]span{{left: 5}}, "Test")
+	check([]span{{right: -5}}, "Test")
+	check([]span{{step: 2}}, "et")
+	check([]span{{step: -2}}, "Ts")
 }
 
 func TestFlagToSpans(t *testing.T) {
@@ -129,6 +288,21 @@ func TestFlagToSpans(t *testing.T) {
 	testOk("1:2,1:", ",", []span{{left: 1, right: 2}, {left: 1}})
 	testOk("1:-2,1:", ",", []span{{left: 1, right: -2}, {left: 1}})
 
+	// steps
+	testOk("::2", ",", []span{{step: 2}})
+	testOk("1::2", ",", []span{{left: 1, step: 2}})
+	testOk("2:-1:3", ",", []span{{left: 2, right: -1, step: 3}})
+	testOk("::-1", ",", []span{{step: -1}})
+	testOk("3:1:-1", ",", []span{{left: 3, right: 1, step: -1}})
+	testOk("1::", ",", []span{{left: 1}})
+
+	// names
+	testOk("a", ",", []span{{leftName: "a", rightName: "a"}})
+	testOk("a:", ",", []span{{leftName: "a"}})
+	testOk(":b", ",", []span{{rightName: "b"}})
+	testOk("a:-1", ",", []span{{leftName: "a", right: -1}})
+	testOk("IMAGE ID:b,1", ",", []span{{leftName: "IMAGE ID", rightName: "b"}, {left: 1, right: 1}})
+
 	testFailed(",", ",")
 	testFailed("1:2,", ",")
 	testFailed(",1:2", ",")
@@ -137,4 +311,241 @@ func TestFlagToSpans(t *testing.T) {
 	testFailed("1::2,", ",")
 	testFailed("4:2,", ",")
 	testFailed("-2:-4,", ",")
+	testFailed("1a", ",")
+	testFailed("a:-1a", ",")
+	testFailed("1:2:0", ",")
+	testFailed("1:2:a", ",")
+	testFailed("1:2:3:4", ",")
+	testFailed("1:3:-1", ",")
+}
+
+func TestFlagToSpansSubFields(t *testing.T) {
+	testOk := func(flag string, expectedSpans []span) {
+		actualSpans, err := flagToSpans(flag, ",")
+		if err != nil {
+			t.Errorf("There should not be an error converting from (%s) but got (%v)", flag, err)
+		}
+
+		if !reflect.DeepEqual(actualSpans, expectedSpans) {
+			t.Errorf("Expected (%+v) Got (%+v) From (%s)", expectedSpans, actualSpans, flag)
+		}
+	}
+
+	testFailed := func(flag string) {
+		if _, err := flagToSpans(flag, ","); err == nil {
+			t.Errorf("There should be an error converting from (%s)", flag)
+		}
+	}
+
+	testOk("3[/]-1", []span{{left: 3, right: 3, subSep: "/", sub: &span{left: -1, right: -1}}})
+	testOk("3[,]2:,1", []span{{left: 3, right: 3, subSep: ",", sub: &span{left: 2}}, {left: 1, right: 1}})
+	testOk("a:[::]1", []span{{leftName: "a", subSep: "::", sub: &span{left: 1, right: 1}}})
+	testOk("2[1:8]", []span{{left: 2, right: 2, chars: &span{left: 1, right: 8}}})
+	testOk("-1[-4:]", []span{{left: -1, right: -1, chars: &span{left: -4}}})
+	testOk("1[/]-1[2]", []span{{left: 1, right: 1, subSep: "/", sub: &span{left: -1, right: -1, chars: &span{left: 2, right: 2}}}})
+	testOk("1[/]1[.]-1", []span{{left: 1, right: 1, subSep: "/", sub: &span{left: 1, right: 1, subSep: ".", sub: &span{left: -1, right: -1}}}})
+
+	testFailed("[/]1")
+	testFailed("1[/]")
+	testFailed("1[]1")
+	testFailed("1[/1")
+	testFailed("1[/]a")
+	testFailed("1[/]1:2:0")
+	testFailed("1[a]")
+	testFailed("1[1[/]1]")
+}
+
+func TestSplitOutsideBrackets(t *testing.T) {
+	test := func(s, sep, expectedJoined string) {
+		if actual := strings.Join(splitOutsideBrackets(s, sep), "|"); actual != expectedJoined {
+			t.Errorf("Expected (%s) Got (%s) For (%s)", expectedJoined, actual, s)
+		}
+	}
+
+	test("", ",", "")
+	test("1", ",", "1")
+	test("1,2", ",", "1|2")
+	test(",", ",", "|")
+	test("1[,]2,3", ",", "1[,]2|3")
+	test("1[,]2[,]3,4", ",", "1[,]2[,]3|4")
+	test("1::2::3", "::", "1|2|3")
+	test("1[,2", ",", "1[|2")
+}
+
+func TestResolveSpans(t *testing.T) {
+	names := []string{"a", "", "c", "c"}
+
+	testOk := func(flag string, expectedSpans []span) {
+		spans, _ := flagToSpans(flag, ",")
+		actualSpans, err := resolveSpans(spans, names)
+		if err != nil {
+			t.Errorf("There should not be an error resolving (%s) but got (%v)", flag, err)
+		}
+
+		if len(actualSpans) != len(expectedSpans) {
+			t.Errorf("Expected %d spans but got %d From (%s)", len(expectedSpans), len(actualSpans), flag)
+			return
+		}
+
+		for i := 0; i < len(expectedSpans); i++ {
+			if actualSpans[i] != expectedSpans[i] {
+				t.Errorf("Expected (%v) Got (%v) From (%s)", expectedSpans[i], actualSpans[i], flag)
+			}
+		}
+	}
+
+	testFailed := func(flag string) {
+		spans, _ := flagToSpans(flag, ",")
+		if _, err := resolveSpans(spans, names); err == nil {
+			t.Errorf("There should be an error resolving (%s)", flag)
+		}
+	}
+
+	testOk("1", []span{{left: 1, right: 1}})
+	testOk("a", []span{{left: 1, right: 1}})
+	testOk("c", []span{{left: 3, right: 3}})
+	testOk("a:c,-1", []span{{left: 1, right: 3}, {left: -1, right: -1}})
+	testOk("-2:c", []span{{left: -2, right: 3}})
+	testOk("c:a:-1", []span{{left: 3, right: 1, step: -1}})
+
+	testFailed("b")
+	testFailed("c:a")
+	testFailed("4:a")
+	testFailed("a:c:-1")
+}
+
+func TestKeepSeperators(t *testing.T) {
+	test := func(r record, columns []column, expected string) {
+		if actual := joinColumns(keepSeperators(r, columns), "|"); actual != expected {
+			t.Errorf("Expected (%s) Got (%s) For (%s)", expected, actual, r.text)
+		}
+	}
+
+	r := cutterToSplitter(singleWsCutter)("web   5 months  ago   1GB")
+	test(r, selectFields([]span{{left: 2, right: 4}}, r), "5 months  ago")
+	test(r, selectFields([]span{{left: 1, right: 1}, {left: 2, right: 3}}, r), "web|5 months")
+	test(r, selectFields([]span{{left: 2, right: 3}, {left: 4, right: 5}}, r), "5 months|ago   1GB")
+	test(r, selectFields([]span{{step: -1}}, r), "1GB|ago|months|5|web")
+	test(r, complement([]span{{left: 3, right: 3}}, r), "web   5|ago   1GB")
+
+	// the text of a field can also be found in the seperator before it
+	r = cutterToSplitter(cutterFromSeperator(" - "))("a - - - b")
+	test(r, selectFields([]span{{left: 2, right: 3}}, r), "- - b")
+
+	// padding trimmed from a field is not part of it
+	r = cutWithCutters("ab  c d", []StringCutter{widthCutter(3), widthCutter(2)})
+	test(r, selectFields([]span{{}}, r), "ab  c d")
+	test(r, selectFields([]span{{left: 2}}, r), "c d")
+
+	// sub-fields are not whole fields and are not merged
+	r = cutterToSplitter(singleWsCutter)("a.b c")
+	test(r, selectFields([]span{{subSep: ".", sub: &span{left: 1, right: 1}}}, r), "a|c")
+	// fields which are not part of the line are not merged
+	r = cutterToSplitter(singleWsCutter)("a b")
+	test(r, fillMissing([]span{{left: 1, right: 3}}, r, "-"), "a b|-")
+}
+
+func TestLocateColumns(t *testing.T) {
+	test := func(r record, columns []column, expected string) {
+		locateColumns(3, r, columns)
+		var actual []string
+		for _, c := range columns {
+			actual = append(actual, fmt.Sprintf("%d:%d:%d:%d:%d", c.line, c.start, c.end, c.runeStart, c.runeEnd))
+		}
+		if strings.Join(actual, "|") != expected {
+			t.Errorf("Expected (%s) Got (%s) For (%s)", expected, strings.Join(actual, "|"), r.text)
+		}
+	}
+
+	r := cutterToSplitter(singleWsCutter)("äb  c d.e")
+	test(r, selectFields([]span{{}}, r), "3:0:3:0:2|3:5:6:4:5|3:7:10:6:9")
+	test(r, selectFields([]span{{left: 3, subSep: ".", sub: &span{left: -1}}}, r), "3:9:10:8:9")
+	test(r, keepSeperators(r, selectFields([]span{{left: 2}}, r)), "3:5:10:4:9")
+	test(r, fillMissing([]span{{left: 4}}, r, "x"), "3:-1:-1:-1:-1")
+
+	// the text of a field can also be found in the seperator before it
+	r = cutterToSplitter(cutterFromSeperator(" - "))("a - - - b")
+	test(r, selectFields([]span{{left: 2, right: 2}}, r), "3:4:5:4:5")
+
+	// sub-fields are where they were cut and not the first of the same text
+	r = cutterToSplitter(multiWsCutter)("x/x")
+	test(r, selectFields([]span{{left: 1, right: 1, subSep: "/", sub: &span{left: 2, right: 2}}}, r), "3:2:3:2:3")
+}
+
+func TestUnsatisfiedSpan(t *testing.T) {
+	test := func(flag string, n int, expected string) {
+		spans, _ := flagToSpans(flag, ",")
+		actual := ""
+		if s, missing := unsatisfiedSpan(spans, n); missing {
+			actual = formatSpan(s)
+		}
+		if actual != expected {
+			t.Errorf("Expected (%s) Got (%s) For (%s) With (%d)", expected, actual, flag, n)
+		}
+	}
+
+	test(":", 0, "")
+	test("1", 0, "1")
+	test("1,2:3", 3, "")
+	test("1,2:4", 3, "2:4")
+	test("-3:", 2, "-3:")
+	test(":-2::2", 2, "")
+	test("4::-1,2", 3, "4::-1")
+	test("1[.]5", 1, "")
+}
+
+func TestFillMissing(t *testing.T) {
+	test := func(flag string, fields []string, expected string) {
+		spans, _ := flagToSpans(flag, ",")
+		if actual := joinColumns(fillMissing(spans, recordOf(fields...), "-"), "|"); actual != expected {
+			t.Errorf("Expected (%s) Got (%s) For (%s)", expected, actual, flag)
+		}
+	}
+
+	fields := []string{"a", "b", "c"}
+	test(":", fields, "a|b|c")
+	test("1,3", fields, "a|c")
+	test("2:5", fields, "b|c|-|-")
+	test("5", fields, "-")
+	test("-5:", fields, "-|-|a|b|c")
+	test("-4,1", fields, "-|a")
+	test("2:", fields, "b|c")
+	test("1:6:2", fields, "a|c|-")
+	test("1,5", []string{}, "-|-")
+
+	// the numbers of the filled in fields are the bounds they were selected by
+	columns := fillMissing([]span{{left: -4, right: 4}}, recordOf(fields...), "-")
+	var numbers []int
+	for _, c := range columns {
+		numbers = append(numbers, c.idx+1)
+	}
+	if !reflect.DeepEqual(numbers, []int{-4, 1, 2, 3, 4}) {
+		t.Errorf("Expected (%v) Got (%v)", []int{-4, 1, 2, 3, 4}, numbers)
+	}
+
+	// the filled in fields are not part of the record
+	if actual := joinOffsets(columns, "|"); actual != "-1:-1|0:1|2:3|4:5|-1:-1" {
+		t.Errorf("Expected (%s) Got (%s)", "-1:-1|0:1|2:3|4:5|-1:-1", actual)
+	}
+}
+
+func TestSelectInOrder(t *testing.T) {
+	test := func(flag string, expected string) {
+		spans, _ := flagToSpans(flag, ",")
+		columns := selectInOrder(spans, recordOf("a", "b", "c", "d"))
+		var actual []string
+		for _, c := range columns {
+			actual = append(actual, fmt.Sprintf("%s%d", c.value, c.span))
+		}
+		if strings.Join(actual, "|") != expected {
+			t.Errorf("Expected (%s) Got (%s) For (%s)", expected, strings.Join(actual, "|"), flag)
+		}
+	}
+
+	test(":", "a0|b0|c0|d0")
+	test("3,1,1:2", "a1|b2|c0")
+	test("-1,1,-1", "a1|d0")
+	test("::-1", "a0|b0|c0|d0")
+	test("2:-2,9", "b0|c0")
+	test("9", "")
 }
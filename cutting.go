@@ -1,8 +1,14 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // wsChars contains all runes/characters that
@@ -16,26 +22,341 @@ const tabCountsAsMultiWs = true
 // a StringSplitter by returning a new function where the
 // StringCutter is applied as long as possible on a provided string.
 func cutterToSplitter(c StringCutter) StringSplitter {
-	return func(s string) []string {
+	return func(s string) record {
 		return cutAllWithCutter(s, c)
 	}
 }
 
+// newRecord returns an empty record of the
+// string with room for n fields.
+func newRecord(s string, n int) record {
+	return record{
+		text:   s,
+		fields: make([]string, 0, n),
+		starts: make([]int, 0, n),
+		ends:   make([]int, 0, n),
+	}
+}
+
+// add appends a field whose text is between start and end.
+func (r *record) add(field string, start, end int) {
+	r.fields = append(r.fields, field)
+	r.starts = append(r.starts, start)
+	r.ends = append(r.ends, end)
+}
+
+// prefixCut returns the cut of a string whose left
+// part is the text in front of the seperator as it is.
+func prefixCut(left, right string, found bool) cut {
+	return cut{left: left, right: right, found: found, end: len(left)}
+}
+
+// trimField returns the text of s between start and end without
+// the whitespace padding it, together with the offsets of it.
+func trimField(s string, start, end int) (string, int, int) {
+	field := strings.TrimLeft(s[start:end], wsChars)
+	start = end - len(field)
+	field = strings.TrimRight(field, wsChars)
+	return field, start, start + len(field)
+}
+
+// stateless returns a function which always returns the same
+// StringSplitter, for splitters which do not need to be created
+// anew for each input.
+func stateless(s StringSplitter) func() StringSplitter {
+	return func() StringSplitter {
+		return s
+	}
+}
+
 // A StringCutter is created from a seperator as long
 // as the seperator is not empty.
 func cutterFromSeperator(sep string) StringCutter {
 	if len(sep) == 0 {
 		panic("cutterFromSeperator should never be used with empty seperators")
 	}
-	return func(s string) (string, string, bool) {
-		return strings.Cut(s, sep)
+	return func(s string) cut {
+		return prefixCut(strings.Cut(s, sep))
+	}
+}
+
+// A StringCutter is created from a seperator which is not
+// cut on when it is inside of double quotes, like in a CSV file
+// (RFC 4180). A field starting with a double quote is returned
+// without its quotes and escaped quotes ("") are unescaped, while
+// its text still includes the quotes.
+func quotedCutterFromSeperator(sep string) StringCutter {
+	if len(sep) == 0 {
+		panic("quotedCutterFromSeperator should never be used with empty seperators")
+	}
+	return func(s string) cut {
+		if !strings.HasPrefix(s, `"`) {
+			return prefixCut(strings.Cut(s, sep))
+		}
+
+		var value strings.Builder
+		rest := s[1:]
+		for {
+			idx := strings.IndexByte(rest, '"')
+			if idx < 0 {
+				// the quote is never closed so the rest belongs to the field
+				value.WriteString(rest)
+				return cut{left: value.String(), end: len(s)}
+			}
+
+			value.WriteString(rest[:idx])
+			rest = rest[idx+1:]
+			if !strings.HasPrefix(rest, `"`) {
+				break
+			}
+			value.WriteByte('"')
+			rest = rest[1:]
+		}
+
+		// anything between the closing quote and the seperator is kept as is
+		end := len(s) - len(rest)
+		after, rest, found := strings.Cut(rest, sep)
+		value.WriteString(after)
+		return cut{left: value.String(), right: rest, found: found, end: end + len(after)}
+	}
+}
+
+// quotedRecordSplit returns a bufio.SplitFunc which splits the input
+// into records like bufio.ScanLines. Unlike it, a line break inside
+// of a double quoted field does not end the record.
+func quotedRecordSplit(sep string) bufio.SplitFunc {
+	bSep := []byte(sep)
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		inQuotes := false
+		for i := 0; i < len(data); i++ {
+			switch {
+			case inQuotes && data[i] == '"':
+				// an escaped quote stays inside of the quotes
+				if i+1 == len(data) && !atEOF {
+					return 0, nil, nil
+				}
+				if i+1 < len(data) && data[i+1] == '"' {
+					i++
+				} else {
+					inQuotes = false
+				}
+			case inQuotes:
+			case data[i] == '"' && (i == 0 || bytes.HasSuffix(data[:i], bSep)):
+				inQuotes = true
+			case data[i] == '\n':
+				return i + 1, bytes.TrimSuffix(data[:i], []byte{'\r'}), nil
+			}
+		}
+
+		if atEOF && len(data) > 0 {
+			return len(data), bytes.TrimSuffix(data, []byte{'\r'}), nil
+		}
+
+		// request more data
+		return 0, nil, nil
 	}
 }
 
+// A StringCutter is created from a regular expression. The string is
+// cut at the first non-empty match, as an expression that matches
+// the empty string would otherwise never stop cutting.
+func cutterFromRegex(re *regexp.Regexp) StringCutter {
+	return func(s string) cut {
+		if loc := re.FindStringIndex(s); loc != nil && loc[1] > loc[0] {
+			return prefixCut(s[:loc[0]], s[loc[1]:], true)
+		}
+
+		// the first match was empty, so look for a later non-empty one
+		for _, loc := range re.FindAllStringIndex(s, -1) {
+			if loc[1] > loc[0] {
+				return prefixCut(s[:loc[0]], s[loc[1]:], true)
+			}
+		}
+
+		return prefixCut(s, "", false)
+	}
+}
+
+// widthCutter creates a StringCutter that cuts a string after
+// the first n characters (runes) no matter what they are.
+// Whitespace used as padding is trimmed from the field.
+func widthCutter(n int) StringCutter {
+	if n <= 0 {
+		panic("widthCutter should never be used with a width less than one")
+	}
+	return func(s string) cut {
+		idx := runeOffset(s, n)
+		left, start, end := trimField(s, 0, idx)
+		return cut{left: left, right: s[idx:], found: idx != len(s), start: start, end: end}
+	}
+}
+
+// runeOffset returns the byte offset of the n'th rune
+// in s, or the length of s if it has less runes.
+func runeOffset(s string, n int) int {
+	for idx := range s {
+		if n == 0 {
+			return idx
+		}
+		n--
+	}
+	return len(s)
+}
+
+// runeOffsetFromEnd returns the byte offset of the n'th rune
+// counted from the end of s, or 0 if s has less runes.
+func runeOffsetFromEnd(s string, n int) int {
+	idx := len(s)
+	for ; n > 0 && idx > 0; n-- {
+		_, size := utf8.DecodeLastRuneInString(s[:idx])
+		idx -= size
+	}
+	return idx
+}
+
+// flagToWidthsSplitter converts the user supplied column widths into a
+// StringSplitter that cuts a line into columns of fixed widths. The widths
+// are counted in characters (runes). One of the widths can be '-' which
+// stands for all characters that are left over, so widths after it are
+// counted from the end of the line. Without it the rest of the line is
+// ignored. Whitespace used as padding is trimmed from each column.
+func flagToWidthsSplitter(s string, sep string) (StringSplitter, error) {
+	var left, right []int
+	variable := false
+	for _, item := range strings.Split(s, sep) {
+		item = strings.Trim(item, wsChars)
+
+		if item == "-" {
+			if variable {
+				return nil, errors.New("there can only be one '-' in the widths")
+			}
+			variable = true
+			continue
+		}
+
+		width, err := strconv.Atoi(item)
+		if err != nil || width <= 0 {
+			return nil, fmt.Errorf("the width '%s' is not a positive integer", item)
+		}
+
+		if variable {
+			right = append(right, width)
+		} else {
+			left = append(left, width)
+		}
+	}
+
+	return func(s string) record {
+		result := newRecord(s, len(left)+len(right)+1)
+		pos := 0
+		for _, width := range left {
+			end := pos + runeOffset(s[pos:], width)
+			result.add(trimField(s, pos, end))
+			pos = end
+		}
+
+		if !variable {
+			return result
+		}
+
+		// the columns at the end are cut from whatever the left ones left over
+		cuts := make([]int, len(right)+1)
+		cuts[len(right)] = len(s)
+		for i := len(right) - 1; i >= 0; i-- {
+			cuts[i] = pos + runeOffsetFromEnd(s[pos:cuts[i+1]], right[i])
+		}
+
+		result.add(trimField(s, pos, cuts[0]))
+		for i := range right {
+			result.add(trimField(s, cuts[i], cuts[i+1]))
+		}
+		return result
+	}, nil
+}
+
+// newHeaderSplitter creates a StringSplitter for tables which are aligned
+// with spaces, like the output of docker ps or kubectl get. The first
+// string it splits is taken as the header of the table and a column
+// starts wherever a word starts after more than one whitespace character.
+// This and all following strings are then cut at these offsets, so that
+// empty values or values containing whitespace do not shift the columns.
+// Header words which are seperated by a single space, like the ones of
+// ps aux or df, can not be told apart from a name like CONTAINER ID and
+// are one column.
+func newHeaderSplitter() StringSplitter {
+	var starts []int
+	return func(s string) record {
+		if starts == nil {
+			starts = headerColumnStarts([]rune(s))
+		}
+		return cutAtColumns(s, starts)
+	}
+}
+
+// headerColumnStarts returns the offsets at which the columns
+// of the header start. The first column always starts at 0.
+func headerColumnStarts(header []rune) []int {
+	starts := []int{0}
+	wsCount := 0
+	seenWord := false
+	for i, r := range header {
+		switch {
+		case tabCountsAsMultiWs && r == '\t':
+			wsCount += 2
+		case strings.ContainsRune(wsChars, r):
+			wsCount++
+		default:
+			if wsCount > 1 && seenWord {
+				starts = append(starts, i)
+			}
+			wsCount = 0
+			seenWord = true
+		}
+	}
+	return starts
+}
+
+// cutAtColumns cuts the string at the rune offset at which every column
+// starts and trims the padding of each. A word that is cut through is
+// kept whole in the later of the two columns, as the cut is moved back
+// to the start of the word. That is where a right aligned number which
+// is longer than its header belongs, while a left aligned value which
+// is longer than its column ends up in the next one.
+func cutAtColumns(s string, starts []int) record {
+	isWs := func(r rune) bool {
+		return strings.ContainsRune(wsChars, r)
+	}
+
+	// the byte offset of each rune and of the end of the string
+	runes := []rune(s)
+	offsets := make([]int, 0, len(runes)+1)
+	for idx := range s {
+		offsets = append(offsets, idx)
+	}
+	offsets = append(offsets, len(s))
+
+	result := newRecord(s, len(starts))
+	prev := 0
+	for _, cut := range starts[1:] {
+		if cut > len(runes) {
+			cut = len(runes)
+		}
+		for cut > prev && cut < len(runes) && !isWs(runes[cut-1]) && !isWs(runes[cut]) {
+			cut--
+		}
+
+		result.add(trimField(s, offsets[prev], offsets[cut]))
+		prev = cut
+	}
+
+	result.add(trimField(s, offsets[prev], len(s)))
+	return result
+}
+
 // A StringCutter that cuts the string into before and after when finding
 // more then one consecutive whitespace characters.
 // Note that all consecutive whitespaces are consumed and not only two.
-func multiWsCutter(s string) (string, string, bool) {
+func multiWsCutter(s string) cut {
 	var (
 		offset, firstWsIdx, secondWsIdx int
 	)
@@ -72,63 +393,89 @@ func multiWsCutter(s string) (string, string, bool) {
 	}
 	// this needs to be the first case after the loop when it ends
 notFound:
-	return s, "", false
+	return prefixCut(s, "", false)
 
 found:
 	//fmt.Println(s, offset, offset+firstWsIdx, offset+firstWsIdx+secondWsIdx+1)
 	// Trim any additional leading ws from the right string as there could be more than two.
-	return s[:offset+firstWsIdx], strings.TrimLeft(s[offset+firstWsIdx+1:], wsChars), true
+	return prefixCut(s[:offset+firstWsIdx], strings.TrimLeft(s[offset+firstWsIdx+1:], wsChars), true)
 }
 
 // A StringCutter that cuts on any whitespace character.
 // Note that all consecutive whitespace it consumed.
-func singleWsCutter(s string) (string, string, bool) {
+func singleWsCutter(s string) cut {
 	idx := strings.IndexAny(s, wsChars)
 	if idx < 0 {
-		return s, "", false
+		return prefixCut(s, "", false)
 	}
 
-	return s[:idx], strings.TrimLeft(s[idx+1:], wsChars), true
+	return prefixCut(s[:idx], strings.TrimLeft(s[idx+1:], wsChars), true)
+}
+
+// extractorFromRegex creates a StringSplitter which does not cut
+// the string but returns the capture groups of the first match of
+// the regular expression. A string that does not match has no parts.
+func extractorFromRegex(re *regexp.Regexp) StringSplitter {
+	return func(s string) record {
+		result := newRecord(s, re.NumSubexp())
+		loc := re.FindStringSubmatchIndex(s)
+		if loc == nil {
+			return result
+		}
+
+		result.found = true
+		for i := 2; i < len(loc); i += 2 {
+			if loc[i] < 0 {
+				result.add("", -1, -1)
+				continue
+			}
+			result.add(s[loc[i]:loc[i+1]], loc[i], loc[i+1])
+		}
+		return result
+	}
 }
 
 // Cuts string using one cutter after another.
 // The result is like strings.Split using different
 // seperators after each cut. The result of this
 // are the parts that the cutters cut the string into.
-func cutWithCutters(s string, cutters []StringCutter) []string {
-	var (
-		match  string
-		found  bool
-		result = make([]string, 0, 2)
-	)
-
+func cutWithCutters(s string, cutters []StringCutter) record {
+	result := newRecord(s, len(cutters)+1)
+	rest := s
 	for _, c := range cutters {
-		match, s, found = c(s)
-
-		result = append(result, match)
+		// what is left to cut is always the end of the string
+		pos := len(s) - len(rest)
+		part := c(rest)
+		result.add(part.left, pos+part.start, pos+part.end)
 
-		if !found {
+		if !part.found {
 			return result
 		}
+		result.found = true
+		rest = part.right
 	}
 
-	return append(result, s)
+	result.add(rest, len(s)-len(rest), len(s))
+	return result
 }
 
 // Apply a cutter on a given string until the cutter is done.
 // Return the parts that the cutter cut the string into.
-func cutAllWithCutter(s string, c StringCutter) []string {
-	var (
-		match  string
-		found  bool = true
-		result      = make([]string, 0, 3)
-	)
-	for found {
-		match, s, found = c(s)
-		result = append(result, match)
-	}
+func cutAllWithCutter(s string, c StringCutter) record {
+	result := newRecord(s, 3)
+	rest := s
+	for {
+		// what is left to cut is always the end of the string
+		pos := len(s) - len(rest)
+		part := c(rest)
+		result.add(part.left, pos+part.start, pos+part.end)
 
-	return result
+		if !part.found {
+			return result
+		}
+		result.found = true
+		rest = part.right
+	}
 }
 
 // predefinedCutters defines special cutters which
@@ -146,11 +493,29 @@ func flagToCutters(s string, sep string) ([]StringCutter, error) {
 	for _, item := range strings.Split(s, sep) {
 		item = strings.Trim(item, wsChars)
 
+		if strings.HasPrefix(item, "<re:") && strings.HasSuffix(item, ">") && len(item) > 5 {
+			re, err := regexp.Compile(item[4 : len(item)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression in '%s': %v", item, err)
+			}
+			result = append(result, cutterFromRegex(re))
+			continue
+		}
+
 		if strings.HasPrefix(item, "<") && strings.HasSuffix(item, ">") && len(item) > 2 {
 			result = append(result, cutterFromSeperator(item[1:len(item)-1]))
 			continue
 		}
 
+		if strings.HasPrefix(item, "w:") {
+			width, err := strconv.Atoi(item[2:])
+			if err != nil || width <= 0 {
+				return nil, fmt.Errorf("the width in '%s' is not a positive integer", item)
+			}
+			result = append(result, widthCutter(width))
+			continue
+		}
+
 		if c, found := predefinedCutters[item]; found {
 			result = append(result, c)
 			continue
@@ -1,15 +1,27 @@
 package main
 
 import (
+	"bufio"
+	"fmt"
+	"regexp"
 	"strings"
 	"testing"
 )
 
+// joinFieldOffsets joins the offsets of the fields of the record.
+func joinFieldOffsets(r record) string {
+	offsets := make([]string, len(r.fields))
+	for i := range r.fields {
+		offsets[i] = fmt.Sprintf("%d:%d", r.starts[i], r.ends[i])
+	}
+	return strings.Join(offsets, "|")
+}
+
 func TestCutterFromSeperator(t *testing.T) {
 	test := func(s, expectedL, expectedR string, findable bool, c StringCutter) {
-		actualL, actualR, found := c(s)
-		if expectedL != actualL || expectedR != actualR || findable != found {
-			t.Errorf("Expected (%s,%s,%v) Got (%s,%s,%v)", expectedL, expectedR, findable, actualL, actualR, found)
+		actual := c(s)
+		if expectedL != actual.left || expectedR != actual.right || findable != actual.found {
+			t.Errorf("Expected (%s,%s,%v) Got (%s,%s,%v)", expectedL, expectedR, findable, actual.left, actual.right, actual.found)
 		}
 	}
 
@@ -43,11 +55,230 @@ func TestCutterFromSeperator(t *testing.T) {
 	testUnfindable("l r", "\t")
 }
 
+func TestQuotedCutterFromSeperator(t *testing.T) {
+	test := func(s, expectedL, expectedR string, findable bool) {
+		actual := quotedCutterFromSeperator(",")(s)
+		if expectedL != actual.left || expectedR != actual.right || findable != actual.found {
+			t.Errorf("Expected (%s,%s,%v) Got (%s,%s,%v) For (%s)", expectedL, expectedR, findable, actual.left, actual.right, actual.found, s)
+		}
+	}
+
+	// unquoted behaves like cutterFromSeperator
+	test("a,b", "a", "b", true)
+	test("a,", "a", "", true)
+	test("a", "a", "", false)
+	test(`a"b,c`, `a"b`, "c", true)
+
+	// quoted
+	test(`"a,b",c`, "a,b", "c", true)
+	test(`"a""b",c`, `a"b`, "c", true)
+	test(`"""a""",c`, `"a"`, "c", true)
+	test(`"",c`, "", "c", true)
+	test("\"a\nb\",c", "a\nb", "c", true)
+	test(`"a,b"`, "a,b", "", false)
+
+	// broken quoting is handled gracefully
+	test(`"a"b,c`, "ab", "c", true)
+	test(`"a,b`, "a,b", "", false)
+
+	// the text of a quoted field includes its quotes
+	testOffsets := func(s string, start, end int) {
+		if actual := quotedCutterFromSeperator(",")(s); actual.start != start || actual.end != end {
+			t.Errorf("Expected (%d:%d) Got (%d:%d) For (%s)", start, end, actual.start, actual.end, s)
+		}
+	}
+
+	testOffsets("a,b", 0, 1)
+	testOffsets(`"a,b",c`, 0, 5)
+	testOffsets(`"a""b"`, 0, 6)
+	testOffsets(`"a"b,c`, 0, 4)
+	testOffsets(`"a,b`, 0, 4)
+}
+
+func TestQuotedRecordSplit(t *testing.T) {
+	test := func(s string, expected string) {
+		scanner := bufio.NewScanner(strings.NewReader(s))
+		scanner.Split(quotedRecordSplit(","))
+
+		var records []string
+		for scanner.Scan() {
+			records = append(records, scanner.Text())
+		}
+
+		if actual := strings.Join(records, "|"); actual != expected {
+			t.Errorf("Expected (%s) Got (%s) For (%q)", expected, actual, s)
+		}
+	}
+
+	test("", "")
+	test("a,b\nc,d\n", "a,b|c,d")
+	test("a,b\r\nc,d", "a,b|c,d")
+	test("a,\"b\nc\",d\ne", "a,\"b\nc\",d|e")
+	test("\"a\"\"\nb\"\nc", "\"a\"\"\nb\"|c")
+	test("a\"b\nc", "a\"b|c")
+	test("\"a\nb", "\"a\nb")
+}
+
+func TestWidthCutter(t *testing.T) {
+	test := func(s string, width int, expectedL, expectedR string, findable bool) {
+		actual := widthCutter(width)(s)
+		if expectedL != actual.left || expectedR != actual.right || findable != actual.found {
+			t.Errorf("Expected (%s,%s,%v) Got (%s,%s,%v) For (%s)", expectedL, expectedR, findable, actual.left, actual.right, actual.found, s)
+		}
+	}
+
+	test("abc", 1, "a", "bc", true)
+	test("abc", 2, "ab", "c", true)
+	test("abc", 3, "abc", "", false)
+	test("abc", 4, "abc", "", false)
+	test("a  b", 3, "a", "b", true)
+	test("   b", 3, "", "b", true)
+	test("äöü", 2, "äö", "ü", true)
+	test("日本語", 1, "日", "本語", true)
+
+	// the padding is not part of the text of the field
+	if actual := widthCutter(4)(" ab c"); actual.start != 1 || actual.end != 3 {
+		t.Errorf("Expected (1:3) Got (%d:%d)", actual.start, actual.end)
+	}
+}
+
+func TestFlagToWidthsSplitter(t *testing.T) {
+	testOk := func(flag, s, expectedJoined string) {
+		splitter, err := flagToWidthsSplitter(flag, ",")
+		if err != nil {
+			t.Errorf("Did not expect to get an error for flag '%s' but got (%v)", flag, err)
+			return
+		}
+
+		if actualJoined := strings.Join(splitter(s).fields, "|"); expectedJoined != actualJoined {
+			t.Errorf("Expected (%s) Got (%s) On (%s) For Flag (%s)", expectedJoined, actualJoined, s, flag)
+		}
+	}
+
+	testFailed := func(flag string) {
+		if _, err := flagToWidthsSplitter(flag, ","); err == nil {
+			t.Errorf("Expected to get an error from '%s'", flag)
+		}
+	}
+
+	testOk("2", "aabbcc", "aa")
+	testOk("2,2", "aabbcc", "aa|bb")
+	testOk("2,2", "a", "a|")
+	testOk("2, 2", "a b c ", "a|b")
+	testOk("2,-", "aabbcc", "aa|bbcc")
+	testOk("-,2", "aabbcc", "aabb|cc")
+	testOk("1,-,1", "abc", "a|b|c")
+	testOk("2,-,2", "abc", "ab||c")
+	testOk("-", " abc ", "abc")
+	testOk("1,1", "äöü", "ä|ö")
+	testOk("1,-,1", "äöü", "ä|ö|ü")
+
+	splitter, _ := flagToWidthsSplitter("2,-,2", ",")
+	if actual := joinFieldOffsets(splitter("a ä b c")); actual != "0:1|2:6|7:8" {
+		t.Errorf("Expected (%s) Got (%s)", "0:1|2:6|7:8", actual)
+	}
+
+	testFailed("")
+	testFailed("a")
+	testFailed("0")
+	testFailed("-1")
+	testFailed("-,-")
+	testFailed("1,,1")
+}
+
+func TestHeaderSplitter(t *testing.T) {
+	test := func(lines []string, expected []string) {
+		splitter := newHeaderSplitter()
+		for i, line := range lines {
+			if actual := strings.Join(splitter(line).fields, "|"); actual != expected[i] {
+				t.Errorf("Expected (%s) Got (%s) For (%s)", expected[i], actual, line)
+			}
+		}
+	}
+
+	test([]string{
+		"CONTAINER ID   IMAGE     PORTS     NAMES",
+		"4c01db0b339c   ubuntu              happy_kirch",
+		"d7886598dbe2   nginx     80/tcp    cool_hertz",
+		"short",
+		"",
+	}, []string{
+		"CONTAINER ID|IMAGE|PORTS|NAMES",
+		"4c01db0b339c|ubuntu||happy_kirch",
+		"d7886598dbe2|nginx|80/tcp|cool_hertz",
+		"short|||",
+		"|||",
+	})
+
+	// leading whitespace and tabs in the header
+	test([]string{"  A  B\tC", "  a  b c"}, []string{"A|B|C", "a|b|c"})
+
+	// right aligned values that are longer than their header
+	test([]string{
+		"USER     PID  CMD",
+		"root       1  init",
+		"root   12345  bash -l",
+	}, []string{
+		"USER|PID|CMD",
+		"root|1|init",
+		"root|12345|bash -l",
+	})
+
+	// columns are counted in characters
+	test([]string{"A    B", "äöü  x"}, []string{"A|B", "äöü|x"})
+
+	// left aligned values that are longer than their column
+	// are kept whole in the next column
+	test([]string{
+		"NAME  READY  STATUS",
+		"web-1-abc    Running",
+	}, []string{
+		"NAME|READY|STATUS",
+		"|web-1-abc|Running",
+	})
+
+	// header words seperated by a single space are one column
+	test([]string{
+		"  PID %CPU COMMAND",
+		"    1  0.0 init",
+	}, []string{
+		"PID %CPU COMMAND",
+		"1  0.0 init",
+	})
+
+	// the offsets are the ones of the bytes without the padding
+	splitter := newHeaderSplitter()
+	splitter("A    B   C")
+	if actual := joinFieldOffsets(splitter("äö   x    y ")); actual != "0:4|7:8|12:13" {
+		t.Errorf("Expected (%s) Got (%s)", "0:4|7:8|12:13", actual)
+	}
+}
+
+func TestCutterFromRegex(t *testing.T) {
+	test := func(s, expr, expectedL, expectedR string, findable bool) {
+		actual := cutterFromRegex(regexp.MustCompile(expr))(s)
+		if expectedL != actual.left || expectedR != actual.right || findable != actual.found {
+			t.Errorf("Expected (%s,%s,%v) Got (%s,%s,%v) For (%s) On (%s)", expectedL, expectedR, findable, actual.left, actual.right, actual.found, expr, s)
+		}
+	}
+
+	test("l;r", ";", "l", "r", true)
+	test("l;|r", "[;|]+", "l", "r", true)
+	test("l;r|r", "[;|]", "l", "r|r", true)
+	test("l   r", " {2,}", "l", "r", true)
+	test("l r", " {2,}", "l r", "", false)
+
+	// expressions which can match the empty string
+	test("l;r", ";*", "l", "r", true)
+	test("lr", ";*", "lr", "", false)
+	test("", ";*", "", "", false)
+}
+
 func TestMultiWsCutter(t *testing.T) {
 	test := func(s, expectedL, expectedR string, findable bool) {
-		actualL, actualR, found := multiWsCutter(s)
-		if expectedL != actualL || expectedR != actualR || findable != found {
-			t.Errorf("Expected (%s,%s,%v) Got (%s,%s,%v) For (%s)", expectedL, expectedR, findable, actualL, actualR, found, s)
+		actual := multiWsCutter(s)
+		if expectedL != actual.left || expectedR != actual.right || findable != actual.found {
+			t.Errorf("Expected (%s,%s,%v) Got (%s,%s,%v) For (%s)", expectedL, expectedR, findable, actual.left, actual.right, actual.found, s)
 		}
 	}
 
@@ -84,9 +315,9 @@ func TestMultiWsCutter(t *testing.T) {
 
 func TestWsSingleCutter(t *testing.T) {
 	test := func(s, expectedL, expectedR string, findable bool) {
-		actualL, actualR, found := singleWsCutter(s)
-		if expectedL != actualL || expectedR != actualR || findable != found {
-			t.Errorf("Expected (%s,%s,%v) Got (%s,%s,%v) For (%s)", expectedL, expectedR, findable, actualL, actualR, found, s)
+		actual := singleWsCutter(s)
+		if expectedL != actual.left || expectedR != actual.right || findable != actual.found {
+			t.Errorf("Expected (%s,%s,%v) Got (%s,%s,%v) For (%s)", expectedL, expectedR, findable, actual.left, actual.right, actual.found, s)
 		}
 	}
 
@@ -106,10 +337,36 @@ func TestWsSingleCutter(t *testing.T) {
 	test("a  ", "a", "", true)
 }
 
+func TestExtractorFromRegex(t *testing.T) {
+	test := func(s, expr, expectedJoined string) {
+		actualJoined := strings.Join(extractorFromRegex(regexp.MustCompile(expr))(s).fields, "|")
+		if expectedJoined != actualJoined {
+			t.Errorf("Expected (%s) Actual (%s) For (%s) On (%s)", expectedJoined, actualJoined, expr, s)
+		}
+	}
+
+	test("user=bob took 12ms", `user=(\w+) took (\d+)ms`, "bob|12")
+	test("user=bob took 12ms", `user=(?P<user>\w+) took (?:\d+)ms`, "bob")
+	test("a=1 b=2", `(\w)=(\d)`, "a|1")
+	test("a=1", `(\w)=(\d)?(x)?`, "a|1|")
+	test("no match", `(\d+)`, "")
+
+	testOffsets := func(s, expr, expected string, found bool) {
+		r := extractorFromRegex(regexp.MustCompile(expr))(s)
+		if actual := joinFieldOffsets(r); actual != expected || r.found != found {
+			t.Errorf("Expected (%s,%v) Got (%s,%v) For (%s) On (%s)", expected, found, actual, r.found, expr, s)
+		}
+	}
+
+	testOffsets("user=bob took 12ms", `user=(\w+) took (\d+)ms`, "5:8|14:16", true)
+	testOffsets("a=1", `(\w)=(x)?`, "0:1|-1:-1", true)
+	testOffsets("no match", `(\d+)`, "", false)
+}
+
 func TestCutWithCutters(t *testing.T) {
 	// it is getting joined on '|' as it is easier to compare
 	test := func(s, expectedJoined string, cutters []StringCutter) {
-		actualJoined := strings.Join(cutWithCutters(s, cutters), "|")
+		actualJoined := strings.Join(cutWithCutters(s, cutters).fields, "|")
 		if expectedJoined != actualJoined {
 			t.Errorf("Expected (%s)  Actual (%s)", expectedJoined, actualJoined)
 		}
@@ -130,11 +387,24 @@ func TestCutWithCutters(t *testing.T) {
 	test("a b\tc", "a|b|c", []StringCutter{sCutter, tCutter})
 	test("a\tb c", "a|b|c", []StringCutter{tCutter, sCutter})
 
+	testOffsets := func(s, expected string, found bool, cutters []StringCutter) {
+		r := cutWithCutters(s, cutters)
+		if actual := joinFieldOffsets(r); actual != expected || r.found != found {
+			t.Errorf("Expected (%s,%v) Got (%s,%v) For (%s)", expected, found, actual, r.found, s)
+		}
+	}
+
+	testOffsets("a b", "0:3", false, nil)
+	testOffsets("a b", "0:3", false, []StringCutter{tCutter})
+	testOffsets("a b\tc", "0:1|2:3|4:5", true, []StringCutter{sCutter, tCutter})
+	testOffsets("a  b c", "0:1|3:6", true, []StringCutter{multiWsCutter})
+	testOffsets(" a bc", "1:2|3:5", true, []StringCutter{widthCutter(3)})
+
 }
 
 func TestCutAllWithCutter(t *testing.T) {
 	test := func(s, expected string, c StringCutter) {
-		actual := strings.Join(cutAllWithCutter(s, c), "|")
+		actual := strings.Join(cutAllWithCutter(s, c).fields, "|")
 		if expected != actual {
 			t.Errorf("Expected (%s) Got (%s)", expected, actual)
 		}
@@ -148,6 +418,22 @@ func TestCutAllWithCutter(t *testing.T) {
 	test("a b", "a|b", cutterFromSeperator(" "))
 	test("a b", "a|b", cutterFromSeperator(" "))
 	test("a b", "a b", cutterFromSeperator("\t"))
+
+	test("a;b|c", "a|b|c", cutterFromRegex(regexp.MustCompile("[;|]")))
+	test("a;;b", "a|b", cutterFromRegex(regexp.MustCompile(";*")))
+
+	testOffsets := func(s, expected string, found bool, c StringCutter) {
+		r := cutAllWithCutter(s, c)
+		if actual := joinFieldOffsets(r); actual != expected || r.found != found {
+			t.Errorf("Expected (%s,%v) Got (%s,%v) For (%s)", expected, found, actual, r.found, s)
+		}
+	}
+
+	testOffsets("a b", "0:3", false, multiWsCutter)
+	testOffsets("a  b\t\tc", "0:1|3:4|6:7", true, multiWsCutter)
+	testOffsets("a - - - b", "0:1|4:5|8:9", true, cutterFromSeperator(" - "))
+	testOffsets("a,", "0:1|2:2", true, cutterFromSeperator(","))
+	testOffsets(`"a,b",c`, "0:5|6:7", true, quotedCutterFromSeperator(","))
 }
 
 func TestFlagToCutters(t *testing.T) {
@@ -185,8 +471,8 @@ func TestFlagToCutters(t *testing.T) {
 		for _, testcase := range testcases {
 
 			// we use cut here which has it's own tests
-			actualJoined := strings.Join(cutWithCutters(testcase, actualCutters), "|")
-			expectedJoined := strings.Join(cutWithCutters(testcase, expectedCutters), "|")
+			actualJoined := strings.Join(cutWithCutters(testcase, actualCutters).fields, "|")
+			expectedJoined := strings.Join(cutWithCutters(testcase, expectedCutters).fields, "|")
 
 			if expectedJoined != actualJoined {
 				t.Errorf("Expected (%s)  Got (%s)  On (%s) For Flag (%s)", expectedJoined, actualJoined, testcase, flag)
@@ -206,16 +492,22 @@ func TestFlagToCutters(t *testing.T) {
 	testOk("t", "|", []StringCutter{cutterFromSeperator("\t")})
 	testOk("m", "|", []StringCutter{multiWsCutter})
 	testOk("<a>", "|", []StringCutter{cutterFromSeperator("a")})
+	testOk("<re:\\s+>", "|", []StringCutter{singleWsCutter})
+	testOk("w:1", "|", []StringCutter{widthCutter(1)})
 
 	// combination
 	testOk("s|s", "|", []StringCutter{cutterFromSeperator(" "), cutterFromSeperator(" ")})
 	testOk("s|t", "|", []StringCutter{cutterFromSeperator(" "), cutterFromSeperator("\t")})
 	testOk("s|<a>", "|", []StringCutter{cutterFromSeperator(" "), cutterFromSeperator("a")})
+	testOk("<re:b+>|s", "|", []StringCutter{cutterFromSeperator("b"), cutterFromSeperator(" ")})
 
 	// current error hanlding on invalid input
 	testFailed("s|t", "-")
 	testFailed("", "|")
 	testFailed("|", "|")
 	testFailed("a|", "|")
+	testFailed("<re:(>", "|")
+	testFailed("w:", "|")
+	testFailed("w:0", "|")
 
 }
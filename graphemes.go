@@ -0,0 +1,215 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// zero width joiner which glues emoji together
+const zwj = '\u200d'
+
+// runeSplitter is a StringSplitter which splits a
+// string into its characters (runes). A byte which is
+// not valid UTF-8 is a character of its own and is
+// kept as it is.
+func runeSplitter(s string) record {
+	result := newRecord(s, len(s))
+	for i := 0; i < len(s); {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		result.add(s[i:i+size], i, i+size)
+		i += size
+	}
+	return result
+}
+
+// byteSplitter is a StringSplitter which splits
+// a string into its bytes.
+func byteSplitter(s string) record {
+	result := newRecord(s, len(s))
+	for i := 0; i < len(s); i++ {
+		result.add(s[i:i+1], i, i+1)
+	}
+	return result
+}
+
+// graphemeSplitter is a StringSplitter which splits a string into
+// user-perceived characters, so that emoji sequences and characters
+// with combining marks are not torn apart. It follows a simplified
+// version of the grapheme cluster rules of Unicode (UAX #29).
+func graphemeSplitter(s string) record {
+	result := newRecord(s, len(s))
+	start := 0
+	prev := rune(-1)
+	// consecutive regional indicators before the current rune
+	riCount := 0
+	for i, r := range s {
+		if i != 0 && !continuesGrapheme(prev, r, riCount) {
+			result.add(s[start:i], start, i)
+			start = i
+		}
+
+		if isRegionalIndicator(r) {
+			riCount++
+		} else {
+			riCount = 0
+		}
+		prev = r
+	}
+
+	if start < len(s) {
+		result.add(s[start:], start, len(s))
+	}
+	return result
+}
+
+// continuesGrapheme reports whether r belongs to the
+// same grapheme cluster as the rune prev before it.
+func continuesGrapheme(prev, r rune, riCount int) bool {
+	switch {
+	case prev == '\r' && r == '\n':
+		return true
+	case r == zwj || isGraphemeExtend(r):
+		return true
+	case prev == zwj && unicode.Is(unicode.So, r):
+		return true
+	case isRegionalIndicator(prev) && isRegionalIndicator(r):
+		// flags are made up of pairs of regional indicators
+		return riCount%2 == 1
+	}
+	return false
+}
+
+// isGraphemeExtend reports whether r extends the grapheme
+// cluster before it, like combining marks, variation selectors,
+// emoji skin tone modifiers and tags do.
+func isGraphemeExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		(r >= 0x1f3fb && r <= 0x1f3ff) ||
+		(r >= 0xe0020 && r <= 0xe007f)
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+// displayWidth returns the number of columns s takes up in
+// a terminal. Wide characters like the ones of east asian
+// scripts and emoji take up two columns.
+func displayWidth(s string) int {
+	width := 0
+	for _, g := range graphemeSplitter(s).fields {
+		width += graphemeWidth(g)
+	}
+	return width
+}
+
+// graphemeWidth returns the number of columns a single
+// grapheme cluster takes up in a terminal.
+func graphemeWidth(g string) int {
+	first, _ := utf8.DecodeRuneInString(g)
+	switch {
+	case !unicode.IsPrint(first) && !unicode.IsSpace(first):
+		return 0
+	case isWide(first) || strings.ContainsRune(g, '\ufe0f') || isRegionalIndicator(first):
+		return 2
+	}
+	return 1
+}
+
+// ranges of wide characters following the east asian width
+// property of Unicode (UAX #11) and the emoji presentation
+var wideRanges = [][2]rune{
+	{0x1100, 0x115f},
+	{0x231a, 0x231b},
+	{0x2329, 0x232a},
+	{0x23e9, 0x23ec},
+	{0x23f0, 0x23f0},
+	{0x23f3, 0x23f3},
+	{0x25fd, 0x25fe},
+	{0x2614, 0x2615},
+	{0x2648, 0x2653},
+	{0x26a1, 0x26a1},
+	{0x26aa, 0x26ab},
+	{0x26bd, 0x26be},
+	{0x26c4, 0x26c5},
+	{0x26d4, 0x26d4},
+	{0x26ea, 0x26ea},
+	{0x26f2, 0x26f5},
+	{0x26fa, 0x26fd},
+	{0x2705, 0x2705},
+	{0x270a, 0x270b},
+	{0x2728, 0x2728},
+	{0x274c, 0x274c},
+	{0x2753, 0x2755},
+	{0x2757, 0x2757},
+	{0x2795, 0x2797},
+	{0x27b0, 0x27b0},
+	{0x27bf, 0x27bf},
+	{0x2b1b, 0x2b1c},
+	{0x2b50, 0x2b50},
+	{0x2b55, 0x2b55},
+	{0x2e80, 0x303e},
+	{0x3041, 0x33ff},
+	{0x3400, 0x4dbf},
+	{0x4e00, 0x9fff},
+	{0xa000, 0xa4cf},
+	{0xa960, 0xa97f},
+	{0xac00, 0xd7a3},
+	{0xf900, 0xfaff},
+	{0xfe10, 0xfe19},
+	{0xfe30, 0xfe6f},
+	{0xff00, 0xff60},
+	{0xffe0, 0xffe6},
+	{0x16fe0, 0x16fe4},
+	{0x17000, 0x18cff},
+	{0x1b000, 0x1b2ff},
+	{0x1f004, 0x1f004},
+	{0x1f0cf, 0x1f0cf},
+	{0x1f18e, 0x1f18e},
+	{0x1f191, 0x1f19a},
+	{0x1f200, 0x1f251},
+	{0x1f300, 0x1f320},
+	{0x1f32d, 0x1f335},
+	{0x1f337, 0x1f37c},
+	{0x1f37e, 0x1f393},
+	{0x1f3a0, 0x1f3ca},
+	{0x1f3cf, 0x1f3d3},
+	{0x1f3e0, 0x1f3f0},
+	{0x1f3f4, 0x1f3f4},
+	{0x1f3f8, 0x1f43e},
+	{0x1f440, 0x1f440},
+	{0x1f442, 0x1f4fc},
+	{0x1f4ff, 0x1f53d},
+	{0x1f54b, 0x1f54e},
+	{0x1f550, 0x1f567},
+	{0x1f57a, 0x1f57a},
+	{0x1f595, 0x1f596},
+	{0x1f5a4, 0x1f5a4},
+	{0x1f5fb, 0x1f64f},
+	{0x1f680, 0x1f6c5},
+	{0x1f6cc, 0x1f6cc},
+	{0x1f6d0, 0x1f6d2},
+	{0x1f6d5, 0x1f6d7},
+	{0x1f6eb, 0x1f6ec},
+	{0x1f6f4, 0x1f6fc},
+	{0x1f7e0, 0x1f7eb},
+	{0x1f90c, 0x1f93a},
+	{0x1f93c, 0x1f945},
+	{0x1f947, 0x1f9ff},
+	{0x1fa70, 0x1faff},
+	{0x20000, 0x2fffd},
+	{0x30000, 0x3fffd},
+}
+
+func isWide(r rune) bool {
+	for _, rng := range wideRanges {
+		if r < rng[0] {
+			return false
+		}
+		if r <= rng[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRuneSplitter(t *testing.T) {
+	test := func(s, expected string) {
+		if actual := strings.Join(runeSplitter(s).fields, "|"); actual != expected {
+			t.Errorf("Expected (%s) Got (%s) For (%s)", expected, actual, s)
+		}
+	}
+
+	test("", "")
+	test("abc", "a|b|c")
+	test("äöü", "ä|ö|ü")
+	test("日本", "日|本")
+	test("a\xffb", "a|\xff|b")
+
+	if actual := joinFieldOffsets(runeSplitter("aä\xffb")); actual != "0:1|1:3|3:4|4:5" {
+		t.Errorf("Expected (%s) Got (%s)", "0:1|1:3|3:4|4:5", actual)
+	}
+}
+
+func TestByteSplitter(t *testing.T) {
+	test := func(s string, expected []string) {
+		actual := byteSplitter(s).fields
+		if strings.Join(actual, "|") != strings.Join(expected, "|") {
+			t.Errorf("Expected (%q) Got (%q) For (%s)", expected, actual, s)
+		}
+	}
+
+	test("", []string{})
+	test("abc", []string{"a", "b", "c"})
+	test("ä", []string{"\xc3", "\xa4"})
+}
+
+func TestGraphemeSplitter(t *testing.T) {
+	test := func(s string, expected []string) {
+		actual := graphemeSplitter(s).fields
+		if strings.Join(actual, "|") != strings.Join(expected, "|") {
+			t.Errorf("Expected (%q) Got (%q) For (%q)", expected, actual, s)
+		}
+	}
+
+	test("", []string{})
+	test("abc", []string{"a", "b", "c"})
+	test("\r\na", []string{"\r\n", "a"})
+
+	// combining marks
+	test("e\u0301a", []string{"e\u0301", "a"})
+	test("a\u0308\u0301", []string{"a\u0308\u0301"})
+
+	// emoji with skin tone and variation selector
+	test("👍🏽!", []string{"👍🏽", "!"})
+	test("\u2764\ufe0fa", []string{"\u2764\ufe0f", "a"})
+
+	// emoji joined with zero width joiners
+	test("👩\u200d👩\u200d👧x", []string{"👩\u200d👩\u200d👧", "x"})
+
+	// flags are pairs of regional indicators
+	test("🇩🇪🇫🇷", []string{"🇩🇪", "🇫🇷"})
+	test("🇩🇪🇫", []string{"🇩🇪", "🇫"})
+}
+
+func TestDisplayWidth(t *testing.T) {
+	test := func(s string, expected int) {
+		if actual := displayWidth(s); actual != expected {
+			t.Errorf("Expected (%d) Got (%d) For (%q)", expected, actual, s)
+		}
+	}
+
+	test("", 0)
+	test("abc", 3)
+	test("äöü", 3)
+	test("é", 1)
+	test("日本語", 6)
+	test("ｱ", 1)
+	test("Ａ", 2)
+	test("한국", 4)
+	test("👍", 2)
+	test("👍🏽", 2)
+	test("👩‍👩‍👧", 2)
+	test("🇩🇪", 2)
+	test("❤️", 2)
+	test("❤", 1)
+	test("a\x00b", 2)
+}
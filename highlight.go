@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// colorMode defines when the output is colored.
+type colorMode string
+
+const (
+	colorAuto   colorMode = "auto"
+	colorAlways colorMode = "always"
+	colorNever  colorMode = "never"
+)
+
+// the ANSI colors the fields of the spans are highlighted
+// with, where the span with index i gets the i'th color
+var highlightColors = []string{
+	"\x1b[1;31m",
+	"\x1b[1;32m",
+	"\x1b[1;33m",
+	"\x1b[1;34m",
+	"\x1b[1;35m",
+	"\x1b[1;36m",
+}
+
+const colorReset = "\x1b[0m"
+
+// highlight returns the line with the text of the columns wrapped in
+// the color of the span which selected them. Columns that overlap with
+// one before them or which are not part of the line are not colored.
+func highlight(line string, columns []column) string {
+	located := make([]column, 0, len(columns))
+	for _, c := range columns {
+		if c.start >= 0 && c.end > c.start {
+			located = append(located, c)
+		}
+	}
+	sort.SliceStable(located, func(i, j int) bool {
+		return located[i].start < located[j].start
+	})
+
+	var b strings.Builder
+	pos := 0
+	for _, c := range located {
+		if c.start < pos {
+			continue
+		}
+
+		color := highlightColors[0]
+		if c.span > 0 {
+			color = highlightColors[c.span%len(highlightColors)]
+		}
+
+		b.WriteString(line[pos:c.start])
+		b.WriteString(color)
+		b.WriteString(line[c.start:c.end])
+		b.WriteString(colorReset)
+		pos = c.end
+	}
+	b.WriteString(line[pos:])
+	return b.String()
+}
+
+// isTerminal reports whether the file is a terminal
+// and not redirected into a file or a pipe.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	test := func(line string, columns []column, expected string) {
+		if actual := highlight(line, columns); actual != expected {
+			t.Errorf("Expected (%q) Got (%q) For (%s)", expected, actual, line)
+		}
+	}
+
+	red, green := highlightColors[0], highlightColors[1]
+
+	test("", nil, "")
+	test("a b", nil, "a b")
+	test("a b", []column{{value: "b", start: 2, end: 3}}, "a "+red+"b"+colorReset)
+	test("a b", []column{{value: "b", start: 2, end: 3, span: 1}, {value: "a", start: 0, end: 1}}, red+"a"+colorReset+" "+green+"b"+colorReset)
+	test("a b", []column{{value: "x", start: -1, end: -1}, {value: "", start: 1, end: 1}}, "a b")
+
+	// overlapping columns are only colored once
+	test("ab", []column{{value: "ab", start: 0, end: 2}, {value: "b", start: 1, end: 2, span: 1}}, red+"ab"+colorReset)
+
+	// the text of a field is colored where it was cut and not
+	// where its value can first be found in the line
+	r := cutterToSplitter(cutterFromSeperator(" - "))("a - - - b")
+	test(r.text, selectFields([]span{{left: 2, right: 2}}, r), "a - "+red+"-"+colorReset+" - b")
+
+	// a quoted field is colored with its quotes
+	r = cutterToSplitter(quotedCutterFromSeperator(","))(`"a,b",c`)
+	test(r.text, selectFields([]span{{left: 1, right: 1}}, r), red+`"a,b"`+colorReset+",c")
+}
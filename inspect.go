@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// inspect writes the fields of the first n records of the inputs as a
+// table each, so that one can see how a line is cut. Each field is shown
+// with its positive and negative index and empty fields are marked, as
+// they are easy to miss. With a header its fields name the fields of
+// the following records. At the end it is reported if the records do
+// not all have the same number of fields.
+func inspect(writer io.Writer, readers []io.Reader, opts options, n int) error {
+	// the numbers of the records by their number of fields
+	counts := make(map[int][]int)
+	recordNumber := 0
+	for _, reader := range readers {
+		chunker := opts.newChunker()
+		names := opts.names
+		isFirstLine := true
+		lineScanner := bufio.NewScanner(reader)
+		lineScanner.Split(opts.split)
+		for recordNumber < n && lineScanner.Scan() {
+			parts := chunker(lineScanner.Text()).fields
+			if isFirstLine && opts.header != headerNone {
+				names = parts
+				isFirstLine = false
+				continue
+			}
+			isFirstLine = false
+
+			recordNumber++
+			counts[len(parts)] = append(counts[len(parts)], recordNumber)
+
+			if _, err := fmt.Fprintf(writer, "record %d: %d fields\n", recordNumber, len(parts)); err != nil {
+				return err
+			}
+			if err := inspectRecord(writer, parts, names); err != nil {
+				return err
+			}
+		}
+
+		if lineScanner.Err() != nil {
+			return lineScanner.Err()
+		}
+	}
+
+	if len(counts) > 1 {
+		_, err := io.WriteString(writer, fieldCountsWarning(counts))
+		return err
+	}
+	return nil
+}
+
+// inspectRecord writes the fields of a record as a table.
+func inspectRecord(writer io.Writer, fields []string, names []string) error {
+	out := newRecordWriter(writer, options{output: outputBox})
+
+	header := []string{"#", "-#", "FIELD", "NOTE"}
+	if hasNamedField(names) {
+		header = append(header, "NAME")
+	}
+	if err := out.writeHeader(inspectColumns(header)); err != nil {
+		return err
+	}
+
+	for i, field := range fields {
+		var note string
+		switch {
+		case len(field) != 0:
+		case i == 0 && len(fields) > 1:
+			note = "empty, leading seperator"
+		case i == len(fields)-1 && len(fields) > 1:
+			note = "empty, trailing seperator"
+		default:
+			note = "empty"
+		}
+
+		row := []string{strconv.Itoa(i + 1), strconv.Itoa(i - len(fields)), field, note}
+		if hasNamedField(names) && i < len(names) {
+			row = append(row, names[i])
+		}
+		if err := out.write(inspectColumns(row), nil); err != nil {
+			return err
+		}
+	}
+
+	if err := out.close(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(writer, "\n")
+	return err
+}
+
+// inspectColumns turns the values of a row into columns.
+func inspectColumns(values []string) []column {
+	columns := make([]column, len(values))
+	for i, v := range values {
+		columns[i] = column{value: v, idx: i}
+	}
+	return columns
+}
+
+// fieldCountsWarning returns the warning that the records have
+// different numbers of fields, listing which records have how many.
+func fieldCountsWarning(counts map[int][]int) string {
+	fieldCounts := make([]int, 0, len(counts))
+	for count := range counts {
+		fieldCounts = append(fieldCounts, count)
+	}
+	sort.Ints(fieldCounts)
+
+	var b strings.Builder
+	b.WriteString("Warning: the records have different numbers of fields\n")
+	for _, count := range fieldCounts {
+		records := make([]string, len(counts[count]))
+		for i, r := range counts[count] {
+			records[i] = strconv.Itoa(r)
+		}
+		fmt.Fprintf(&b, "    %d fields: records %s\n", count, strings.Join(records, ", "))
+	}
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	test := func(input string, opts options, n int, expected string) {
+		var b strings.Builder
+		opts.split = bufio.ScanLines
+		opts.newChunker = stateless(cutterToSplitter(multiWsCutter))
+		if err := inspect(&b, []io.Reader{strings.NewReader(input)}, opts, n); err != nil {
+			t.Errorf("Expected (%q) Got error (%v)", expected, err)
+		}
+		if b.String() != expected {
+			t.Errorf("Expected\n%s\nGot\n%s", expected, b.String())
+		}
+	}
+
+	test("", options{header: headerNone}, 5, "")
+
+	test("a  b\n", options{header: headerNone}, 5, ""+
+		"record 1: 2 fields\n"+
+		"┌───┬────┬───────┬──────┐\n"+
+		"│ # │ -# │ FIELD │ NOTE │\n"+
+		"├───┼────┼───────┼──────┤\n"+
+		"│ 1 │ -2 │ a     │      │\n"+
+		"│ 2 │ -1 │ b     │      │\n"+
+		"└───┴────┴───────┴──────┘\n"+
+		"\n")
+
+	// only the first records are inspected
+	test("a\nb\n", options{header: headerNone}, 1, ""+
+		"record 1: 1 fields\n"+
+		"┌───┬────┬───────┬──────┐\n"+
+		"│ # │ -# │ FIELD │ NOTE │\n"+
+		"├───┼────┼───────┼──────┤\n"+
+		"│ 1 │ -1 │ a     │      │\n"+
+		"└───┴────┴───────┴──────┘\n"+
+		"\n")
+
+	test("N  M\n  b\n", options{header: headerKeep}, 5, ""+
+		"record 1: 2 fields\n"+
+		"┌───┬────┬───────┬──────────────────────────┬──────┐\n"+
+		"│ # │ -# │ FIELD │ NOTE                     │ NAME │\n"+
+		"├───┼────┼───────┼──────────────────────────┼──────┤\n"+
+		"│ 1 │ -2 │       │ empty, leading seperator │ N    │\n"+
+		"│ 2 │ -1 │ b     │                          │ M    │\n"+
+		"└───┴────┴───────┴──────────────────────────┴──────┘\n"+
+		"\n")
+}
+
+func TestFieldCountsWarning(t *testing.T) {
+	expected := "Warning: the records have different numbers of fields\n" +
+		"    1 fields: records 2\n" +
+		"    3 fields: records 1, 3\n"
+	if actual := fieldCountsWarning(map[int][]int{3: {1, 3}, 1: {2}}); actual != expected {
+		t.Errorf("Expected (%q) Got (%q)", expected, actual)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import "errors"
+
+// numberedLine is a line together with its 1-based number.
+type numberedLine struct {
+	number int
+	text   string
+}
+
+// A lineSelector selects the lines of an input with spans like fields
+// are selected from a line, while the lines are kept in their order.
+// As long as the number of lines is not known, lines which could be
+// selected by a negative bound are held back in a ring buffer, which
+// is only as big as the biggest negative bound, so that the rest of
+// the input can still be streamed.
+type lineSelector struct {
+	spans []span
+	// the lines held back, of which the oldest is at head,
+	// and the number of lines pushed before the oldest one
+	buffer []numberedLine
+	head   int
+	held   int
+	start  int
+}
+
+// newLineSelector creates a lineSelector for one input. The spans can
+// not have names, sub-selections or a negative step, as lines are
+// always written in the order they are read.
+func newLineSelector(spans []span) (*lineSelector, error) {
+	size := 0
+	for _, s := range spans {
+		switch {
+		case hasNames([]span{s}):
+			return nil, errors.New("lines can only be selected by their number")
+		case s.sub != nil || s.chars != nil:
+			return nil, errors.New("lines can not be selected with sub-selections")
+		case s.step < 0:
+			return nil, errors.New("lines can not be selected backwards")
+		}
+		if -s.left > size {
+			size = -s.left
+		}
+		if -s.right > size {
+			size = -s.right
+		}
+	}
+	return &lineSelector{spans: spans, buffer: make([]numberedLine, size)}, nil
+}
+
+// push adds the next line and returns the lines that are
+// known to be selected by now, which it may be itself.
+func (l *lineSelector) push(text string) []numberedLine {
+	idx := l.start + l.held
+	line := numberedLine{number: idx + 1, text: text}
+	if len(l.buffer) == 0 {
+		l.start++
+		if l.selects(idx, -1) {
+			return []numberedLine{line}
+		}
+		return nil
+	}
+
+	if l.held < len(l.buffer) {
+		l.buffer[(l.head+l.held)%len(l.buffer)] = line
+		l.held++
+		return nil
+	}
+
+	// the oldest line is far enough from the end to decide about it
+	var result []numberedLine
+	if l.selects(l.start, -1) {
+		result = append(result, l.buffer[l.head])
+	}
+	l.buffer[l.head] = line
+	l.head = (l.head + 1) % len(l.buffer)
+	l.start++
+	return result
+}
+
+// flush returns the selected lines of the ones held back,
+// once the end of the input, and so its length, is known.
+func (l *lineSelector) flush() []numberedLine {
+	n := l.start + l.held
+	var result []numberedLine
+	for i := 0; i < l.held; i++ {
+		if l.selects(l.start+i, n) {
+			result = append(result, l.buffer[(l.head+i)%len(l.buffer)])
+		}
+	}
+	l.start, l.held = n, 0
+	return result
+}
+
+// done reports whether none of the following lines can be
+// selected anymore, so that the input does not need to be read.
+func (l *lineSelector) done() bool {
+	for _, s := range l.spans {
+		if s.right <= 0 || s.left < 0 || l.start < s.right {
+			return false
+		}
+	}
+	return true
+}
+
+// selects reports whether the 0-based line idx is selected from the n
+// lines of the input. Without knowing n it is only known that the line
+// is further away from the end than any of the negative bounds reach.
+func (l *lineSelector) selects(idx, n int) bool {
+	for _, s := range l.spans {
+		step := s.step
+		if step == 0 {
+			step = 1
+		}
+
+		var lo, hi int
+		if n >= 0 {
+			lo, hi = bounds(s, n)
+		} else {
+			// the line is in front of any negative bound
+			if s.left < 0 {
+				continue
+			}
+			lo, hi = 0, idx+1
+			if s.left > 0 {
+				lo = s.left - 1
+			}
+			if s.right > 0 {
+				hi = s.right
+			}
+		}
+
+		if lo <= idx && idx < hi && (idx-lo)%step == 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLineSelector(t *testing.T) {
+	// the lines are expected to be the ones the spans select
+	// from all lines, without duplicates and in their order
+	check := func(flag string, n int) {
+		spans, err := flagToSpans(flag, ",")
+		if err != nil {
+			t.Errorf("Expected no error Got (%v) For (%s)", err, flag)
+			return
+		}
+		l, err := newLineSelector(spans)
+		if err != nil {
+			t.Errorf("Expected no error Got (%v) For (%s)", err, flag)
+			return
+		}
+
+		selected := make(map[int]bool)
+		for _, s := range spans {
+			for _, idx := range indices(s, n) {
+				selected[idx] = true
+			}
+		}
+		var expected []string
+		for idx := range selected {
+			expected = append(expected, strconv.Itoa(idx+1))
+		}
+		sort.Slice(expected, func(i, j int) bool {
+			a, _ := strconv.Atoi(expected[i])
+			b, _ := strconv.Atoi(expected[j])
+			return a < b
+		})
+
+		var actual []string
+		for i := 0; i < n; i++ {
+			for _, line := range l.push(strconv.Itoa(i + 1)) {
+				if line.text != strconv.Itoa(line.number) {
+					t.Errorf("Expected (%s) Got (%d) For (%s)", line.text, line.number, flag)
+				}
+				actual = append(actual, line.text)
+			}
+		}
+		for _, line := range l.flush() {
+			actual = append(actual, line.text)
+		}
+
+		if strings.Join(actual, ",") != strings.Join(expected, ",") {
+			t.Errorf("Expected (%v) Got (%v) For (%s) With (%d) lines", expected, actual, flag, n)
+		}
+	}
+
+	for _, flag := range []string{":", "1", "2:", "-1", "-3:", ":-2", "2:-2", "-3:4", "::2", "2::3", "-5::2", "1,-1", "3:4,-2:", "-2:2", "4:-4"} {
+		for n := 0; n < 8; n++ {
+			check(flag, n)
+		}
+	}
+}
+
+func TestLineSelectorDone(t *testing.T) {
+	test := func(flag string, pushed int, expected bool) {
+		spans, _ := flagToSpans(flag, ",")
+		l, _ := newLineSelector(spans)
+		for i := 0; i < pushed; i++ {
+			l.push("")
+		}
+		if actual := l.done(); actual != expected {
+			t.Errorf("Expected (%t) Got (%t) For (%s) After (%d)", expected, actual, flag, pushed)
+		}
+	}
+
+	test("1:2", 1, false)
+	test("1:2", 2, true)
+	test("1:2,5", 2, false)
+	test("1:2,5", 5, true)
+	test("2:", 10, false)
+	test("-2:3", 10, false)
+}
+
+func TestNewLineSelector(t *testing.T) {
+	testFailed := func(spans []span) {
+		if _, err := newLineSelector(spans); err == nil {
+			t.Errorf("Expected error For (%v)", spans)
+		}
+	}
+
+	testFailed([]span{{leftName: "NAME"}})
+	testFailed([]span{{sub: &span{}}})
+	testFailed([]span{{chars: &span{}}})
+	testFailed([]span{{step: -1}})
+}
@@ -7,6 +7,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"unicode/utf8"
 )
 
 var usage = `
@@ -16,28 +18,98 @@ Print selected parts of lines from each FILE to standard output.
 With no FILE, or when FILE is -, read standard input.
 
     -f      --fields FIELDS                 select only these fields; also print any line
-                                                that contains no delimiter character.
+                                                that contains no delimiter character, unless
+                                                the -s option is specified.
                                                 Fields can be used more than once
+    -c      --characters FIELDS             select only these characters; the line is split
+                                                into characters instead of fields
+    -b      --bytes FIELDS                  select only these bytes; the line is split
+                                                into bytes instead of fields
+    -g      --graphemes                     with -c, select user-perceived characters so that
+                                                emoji and combining marks are not split
+    -v      --complement                    select all fields except the ones in FIELDS
+    -s      --only-delimited                do not print lines not containing delimiters
+    -ud     --undelimited FILE              write the lines not containing delimiters to FILE,
+                                                or to standard error if FILE is -, instead;
+                                                implies -s
+    -ord    --order ORDER                   write the selected fields in the order of FIELDS
+                                                (spec) or in the order of the line (input)
+                                                Default: spec
+    -st     --strict                        fail when a line does not have the selected fields
+    -m      --missing VALUE                 fill the selected fields which a line does not
+                                                have with VALUE, so that the other fields
+                                                keep their position
+    -ks     --keep-seperators               keep the original text between selected fields
+                                                which are next to each other instead of
+                                                using the output seperator
+    -l      --lines LINES                   only read these lines of each input
+    -w      --where PREDICATE               only write the lines for which PREDICATE holds;
+                                                can be given more than once
+    -hdr    --header MODE                   how to treat the first line of each input
+                                                Default: none
     -cw     --cut-on-whitespace             cut on any whitespace but also trim following
                                                 whitespace aswell until first non-whitepsace
     -cmw    --cut-on-multi-whitespace       cut on consecutive whitespace but also trim following
                                                 whitespace aswell until first non-whitepsace
     -cs     --cut-on-seperator STR          cut whenever STR is encountered in a line
+    -cq     --cut-on-quoted-seperator STR   cut whenever STR is encountered outside of double
+                                                quotes like in CSV (RFC 4180); quoted fields
+                                                are unquoted and can span multiple lines
+    -cr     --cut-on-regex REGEX            cut whenever REGEX matches in a line
     -cf     --cut-on-format DELIMS          cut line applying one delimiter in STR after
                                                 another
+    -cfw    --cut-on-fixed-widths WIDTHS    cut line into columns of fixed widths; padding
+                                                whitespace is trimmed from each column
+    -ch     --cut-on-header                 cut line at the offsets where the columns of
+                                                the header (first line) start; a column starts
+                                                after more than one whitespace character, so
+                                                header words seperated by a single space, like
+                                                the ones of ps aux or df, are one column
+    -ce     --extract REGEX                 use the capture groups of the first match of REGEX
+                                                as fields; lines without a match have no fields
+    -o      --output FORMAT                 write the selected fields in FORMAT
+                                                Default: plain
+    -off    --offsets                       write the line number, the field number and the
+                                                start and end offsets in bytes and runes of each
+                                                selected field instead; needs -o json, ndjson
+                                                or tsv; with -cq the line is the number of the
+                                                record and the offsets count from its start
+    -hl     --highlight                     write each line as it is but with the selected
+                                                fields colored, each range in its own color
+    -clr    --color WHEN                    color the output always, never or auto when
+                                                writing to a terminal
+                                                Default: auto
+    -i      --inspect                       show how the first lines are cut instead, with
+                                                the positive and negative index of each field
+    -n      --inspect-count N               the number of lines to inspect
+                                                Default: 5
+    -tpl    --format TEMPLATE               write the selected fields into TEMPLATE instead
+                                                of seperating them by the output seperator
+    -ts     --table-sample N                compute the widths of a table from the first N
+                                                lines only, so that the rest can be streamed
+                                                Default: 0 (all lines)
     -fsep   --format-seperator STR          use STR as seperator in the DELIMS specification
                                                 Default: ','
     -osep   --ouput-seperator STR           use the STR as the output field seperator
-                                                Default: ' '
+                                                Default: ' ', '' with -c or -b, ',' with csv
 
 Only one of the following can be used at a time:
     -cw
     -cmw
     -cs
+    -cq
+    -cr
     -cf
+    -cfw
+    -ch
+    -ce
+    -c
+    -b
 
 FIELDS is made up of one range, or many ranges separated by commas.
-Selected input is written in the same order that it is read.
+The selected fields are written in the order of the ranges and as often
+as they are selected, or like cut does only once each in the order they
+are read with --order input.
 Each range is one of:
 
     N     N'th field, counted from 1
@@ -49,6 +121,61 @@ Each range is one of:
     :-M   from first to ((num items on line) - M)'th (included) field
     :     from beginning to end of line
 
+Each range with a ':' can be followed by ':S' to select only every S'th
+field of the range. With a negative S the fields are selected backwards
+from N to M, where N defaults to the last and M to the first field.
+
+Each range can be followed by [SEP] and another range, to split the
+selected fields again on SEP and select the sub-fields of that range.
+When [SEP] is the end of the range, then SEP is a range itself which
+selects the characters of each selected field instead.
+
+Instead of a number N or M the name of a field can be used. Names
+are looked up in the first line of each input, or are the names of
+the capture groups like (?P<name>...) when extracting.
+
+MODE is one of:
+    none    the first line is not treated specially
+    keep    the first line of each input is a header
+    once    like keep, but only the first header is printed
+    skip    like keep, but no header is printed
+
+FORMAT is one of:
+    plain   the fields seperated by the output seperator
+    json    a JSON array with an array of the fields of each line, or an object
+                when the fields are named by a header or by capture groups
+    ndjson  like json, but each line is written as its own JSON value
+    csv     the fields seperated by the output seperator, which defaults to ','
+                and has to be a single character, quoted like in CSV (RFC 4180)
+    tsv     the fields seperated by tabs, where tabs, line breaks and '\'
+                in the fields are escaped as \t, \n, \r and \\
+    table   the fields aligned in columns
+    box     like table, but with a box drawn around each field
+    markdown    like table, but as a Markdown table
+
+TEMPLATE is text with placeholders like {1} or {NAME}, which are replaced
+by the fields selected by the range between the braces. A placeholder
+selecting more than one field is replaced by the fields seperated by the
+output seperator. Use {{ and }} for literal braces. A TEMPLATE replaces
+FIELDS and can not be used with an output FORMAT.
+
+LINES is made up of ranges like FIELDS, but they select lines instead
+and the lines are always written in the order they are read. Lines are
+counted from the first line of each input, including a header. A header
+is treated as one whether it is selected or not, and names as well as
+the columns of -ch are always taken from the first line.
+
+PREDICATE is made up of a range like in FIELDS, an operator and a value.
+It holds if it holds for any of the fields selected by the range.
+The operator is one of:
+    ~       the field matches the regular expression
+    !~      the field does not match the regular expression
+    =       the field is the value
+    !=      the field is not the value
+    <, <=, >, >=
+            the field compared to the value, which is a number, a duration
+            like 1h30m or 500ms, or a size like 10MB, 1.5G or 512KiB
+
 DELIMS is made up on one seperator, or many seperators seperated by commas.
 The seperator of DELIMS can be changed using --format-seperator.
 Each delimiter can be one of:
@@ -56,10 +183,18 @@ Each delimiter can be one of:
     t                   cut on next tab
     w                   cut on next whitespace but consume all consecutive aswell
     m                   cut on next multi whitespace and consume all consecutive aswell
-    <str> cut on next encounter of str, where str can by any string
+    <str>               cut on next encounter of str, where str can by any string
+    <re:regex>          cut on next match of regex
+    w:N                 cut after the next N characters
 
 Note: whitespace always means tab and space.
 
+WIDTHS is made up of one width, or many widths seperated by commas.
+Each width is a number of characters, or '-' which can be used once
+and stands for what is left over of the line. Widths after '-' are
+counted from the end of the line. Without '-' the rest of the line
+is ignored.
+
 Examples:
     $ echo "A B C" | gut -cw -f 2:
     B C
@@ -72,24 +207,120 @@ Examples:
 
     $ echo "A  B,C" | gut -cf "s|<,>" -fsep "|"
     A  B C
+
+    $ echo 'A,"B,C",D' | gut -cq ","
+    A B,C D
+
+    $ echo "A;B|C   D" | gut -cr "[;|]| {2,}"
+    A B C D
+
+    $ echo "k1 v1 k2 v2" | gut -cw -f 2::2
+    v1 v2
+
+    $ echo "A B C" | gut -cw -f ::-1
+    C B A
+
+    $ echo "/usr/bin/env localhost:8080" | gut -cw -f "1[/]-1,2[:]2"
+    env 8080
+
+    $ echo "2023-01-02T10:11:12" | gut -c -8:
+    10:11:12
+
+    $ echo "3f2a9c1d8e main 2023-01-02T10:11:12" | gut -cw -f "1[:7],-1[-8:]"
+    3f2a9c1 10:11:12
+
+    $ printf "NAME  AGE\nbob   42\n" | gut -hdr keep -o ndjson
+    {"NAME":"bob","AGE":"42"}
+
+    $ echo "A B C D" | gut -cw -v -f 2,-1
+    A C
+
+    $ printf "NAME  IMAGE ID\nweb   1f2e\n" | gut -f "IMAGE ID,NAME"
+    IMAGE ID NAME
+    1f2e web
+
+    $ echo "A   B    C" | gut -cfw "4,-,1" -f 3,2
+    C B
+
+    $ echo "user=bob took 12ms" | gut -ce "user=(?P<user>\w+) took (\d+)ms" -f 2,user
+    12 bob
+
+    $ printf "web   5 months ago   1GB\n" | gut -cw -ks -f 2:4
+    5 months ago
+
+    $ echo "äb  c" | gut -f 2 -off -o ndjson
+    {"line":1,"field":2,"start":5,"end":6,"runeStart":4,"runeEnd":5,"value":"c"}
+
+    $ printf "a 10MB\nb 1.5GB\n" | gut -cw -w "-1>100MB" -f 1
+    b
+
+    $ printf "A\nB\nC\nD\n" | gut -l 1,-2:
+    A
+    C
+    D
+
+    $ printf "a b c\nd e\n" | gut -cw -f 2:3 -m "-"
+    b c
+    e -
+
+    $ printf "a,b\nc\n" | gut -cs , -s -f 2
+    b
+
+    $ echo "A B C" | gut -cw -f 3,1,1:2 -ord input
+    A B C
+
+    $ echo "bob 10.0.0.1 22" | gut -cw -tpl "ssh -p {-1} {1}@{2}"
+    ssh -p 22 bob@10.0.0.1
 `
 
 // selection options
 var fieldsArg = arg[string]{aliases: []string{"f", "fields"}}
+var charactersArg = arg[string]{aliases: []string{"c", "characters"}}
+var bytesArg = arg[string]{aliases: []string{"b", "bytes"}}
+var graphemesArg = arg[bool]{aliases: []string{"g", "graphemes"}}
+var complementArg = arg[bool]{aliases: []string{"v", "complement"}}
+var keepSeperatorsArg = arg[bool]{aliases: []string{"ks", "keep-seperators"}}
 
 // cutting options
 var cutOnWhitespaceArg = arg[bool]{aliases: []string{"cw", "cut-on-whitespace"}}
 var cutOnMultiWhitespaceArg = arg[bool]{aliases: []string{"cmw", "cut-on-multi-whitespace"}}
 var cutOnSeperatorArg = arg[string]{aliases: []string{"cs", "cut-on-seperator"}}
+var cutOnQuotedSeperatorArg = arg[string]{aliases: []string{"cq", "cut-on-quoted-seperator"}}
+var cutOnRegexArg = arg[string]{aliases: []string{"cr", "cut-on-regex"}}
 var cutOnFormatArg = arg[string]{aliases: []string{"cf", "cut-on-format"}}
+var cutOnFixedWidthsArg = arg[string]{aliases: []string{"cfw", "cut-on-fixed-widths"}}
+var cutOnHeaderArg = arg[bool]{aliases: []string{"ch", "cut-on-header"}}
+var extractArg = arg[string]{aliases: []string{"ce", "extract"}}
+
+// header options
+var headerArg = arg[string]{aliases: []string{"hdr", "header"}, defaultValue: string(headerNone)}
+
+// output options
+var outputArg = arg[string]{aliases: []string{"o", "output"}, defaultValue: string(outputPlain)}
+var offsetsArg = arg[bool]{aliases: []string{"off", "offsets"}}
+var highlightArg = arg[bool]{aliases: []string{"hl", "highlight"}}
+var colorArg = arg[string]{aliases: []string{"clr", "color"}, defaultValue: string(colorAuto)}
+var inspectArg = arg[bool]{aliases: []string{"i", "inspect"}}
+var inspectCountArg = arg[int]{aliases: []string{"n", "inspect-count"}, defaultValue: 5}
+var whereArg = arg[listValue]{aliases: []string{"w", "where"}}
+var linesArg = arg[string]{aliases: []string{"l", "lines"}}
+var orderArg = arg[string]{aliases: []string{"ord", "order"}, defaultValue: string(orderSpec)}
+var strictArg = arg[bool]{aliases: []string{"st", "strict"}}
+var onlyDelimitedArg = arg[bool]{aliases: []string{"s", "only-delimited"}}
+var undelimitedArg = arg[string]{aliases: []string{"ud", "undelimited"}}
+var missingArg = arg[string]{aliases: []string{"m", "missing"}}
+var templateArg = arg[string]{aliases: []string{"tpl", "format"}}
+var tableSampleArg = arg[int]{aliases: []string{"ts", "table-sample"}}
 
 // seperators
 var formatSeperatorArg = arg[string]{aliases: []string{"fsep", "format-seperator"}, defaultValue: ","}
 var outputSeperatorArg = arg[string]{aliases: []string{"osep", "output-seperator"}, defaultValue: " "}
 
 func setupFlags() {
-	sArgs := []*arg[string]{&fieldsArg, &cutOnSeperatorArg, &cutOnFormatArg, &formatSeperatorArg, &outputSeperatorArg}
-	bArgs := []*arg[bool]{&cutOnWhitespaceArg, &cutOnMultiWhitespaceArg}
+	sArgs := []*arg[string]{&fieldsArg, &charactersArg, &bytesArg, &headerArg, &linesArg, &orderArg, &missingArg, &undelimitedArg, &outputArg, &templateArg, &colorArg, &cutOnSeperatorArg, &cutOnQuotedSeperatorArg, &cutOnRegexArg, &cutOnFormatArg, &cutOnFixedWidthsArg, &extractArg, &formatSeperatorArg, &outputSeperatorArg}
+	bArgs := []*arg[bool]{&graphemesArg, &complementArg, &keepSeperatorsArg, &offsetsArg, &highlightArg, &inspectArg, &strictArg, &onlyDelimitedArg, &cutOnWhitespaceArg, &cutOnMultiWhitespaceArg, &cutOnHeaderArg}
+	iArgs := []*arg[int]{&tableSampleArg, &inspectCountArg}
+	lArgs := []*arg[listValue]{&whereArg}
 
 	for _, sArg := range sArgs {
 		for _, alias := range sArg.aliases {
@@ -103,6 +334,18 @@ func setupFlags() {
 		}
 	}
 
+	for _, iArg := range iArgs {
+		for _, alias := range iArg.aliases {
+			flag.IntVar(&iArg.value, alias, iArg.defaultValue, "")
+		}
+	}
+
+	for _, lArg := range lArgs {
+		for _, alias := range lArg.aliases {
+			flag.Var(&lArg.value, alias, "")
+		}
+	}
+
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, usage, os.Args[0])
 	}
@@ -110,53 +353,381 @@ func setupFlags() {
 	flag.Parse()
 }
 
-func getGutter() StringSplitter {
+// getGutter returns a function creating the StringSplitter selected
+// by the user for each input, together with the names of the fields
+// it produces, if these are already known before reading any input.
+func getGutter() (func() StringSplitter, []string) {
 	// ensure that only one action is performed
 	actionCount := countValue(
 		true,
 		cutOnWhitespaceArg.value,
 		cutOnMultiWhitespaceArg.value,
 		len(cutOnSeperatorArg.value) != 0,
-		len(cutOnFormatArg.value) != 0)
+		len(cutOnQuotedSeperatorArg.value) != 0,
+		len(cutOnRegexArg.value) != 0,
+		len(cutOnFormatArg.value) != 0,
+		len(cutOnFixedWidthsArg.value) != 0,
+		cutOnHeaderArg.value,
+		len(extractArg.value) != 0,
+		len(charactersArg.value) != 0,
+		len(bytesArg.value) != 0)
 
 	if actionCount > 1 {
 		die("You can only use one of the cutting actions but you specified more than one")
 	}
 
+	if graphemesArg.value && len(charactersArg.value) == 0 {
+		die("Error: graphemes can only be selected together with characters")
+	}
+
+	// unquoted fields are not the text of the line anymore
+	if keepSeperatorsArg.value && len(cutOnQuotedSeperatorArg.value) != 0 {
+		die("Error: the seperators can not be kept when cutting on a quoted seperator")
+	}
+
+	// the text between capture groups is no seperator
+	if keepSeperatorsArg.value && len(extractArg.value) != 0 {
+		die("Error: the seperators can not be kept when extracting fields")
+	}
+
 	switch {
 	case cutOnWhitespaceArg.value:
-		return cutterToSplitter(singleWsCutter)
+		return stateless(cutterToSplitter(singleWsCutter)), nil
 	case cutOnMultiWhitespaceArg.value:
-		return cutterToSplitter(multiWsCutter)
+		return stateless(cutterToSplitter(multiWsCutter)), nil
 	case len(cutOnSeperatorArg.value) != 0:
-		return cutterToSplitter(cutterFromSeperator(cutOnSeperatorArg.value))
+		return stateless(cutterToSplitter(cutterFromSeperator(cutOnSeperatorArg.value))), nil
+	case len(cutOnQuotedSeperatorArg.value) != 0:
+		return stateless(cutterToSplitter(quotedCutterFromSeperator(cutOnQuotedSeperatorArg.value))), nil
+	case len(cutOnRegexArg.value) != 0:
+		re, err := regexp.Compile(cutOnRegexArg.value)
+		if err != nil {
+			die("Error: invalid regular expression: %v", err)
+		}
+		return stateless(cutterToSplitter(cutterFromRegex(re))), nil
 	case len(cutOnFormatArg.value) != 0:
 		cutters, err := flagToCutters(cutOnFormatArg.value, formatSeperatorArg.value)
 		if err != nil {
 			die("Error: %v", err)
 		}
-		return func(s string) []string {
+		return stateless(func(s string) record {
 			return cutWithCutters(s, cutters)
+		}), nil
+	case len(cutOnFixedWidthsArg.value) != 0:
+		splitter, err := flagToWidthsSplitter(cutOnFixedWidthsArg.value, ",")
+		if err != nil {
+			die("Error: %v", err)
+		}
+		return stateless(splitter), nil
+	case cutOnHeaderArg.value:
+		return newHeaderSplitter, nil
+	case len(charactersArg.value) != 0 && graphemesArg.value:
+		return stateless(graphemeSplitter), nil
+	case len(charactersArg.value) != 0:
+		return stateless(runeSplitter), nil
+	case len(bytesArg.value) != 0:
+		return stateless(byteSplitter), nil
+	case len(extractArg.value) != 0:
+		re, err := regexp.Compile(extractArg.value)
+		if err != nil {
+			die("Error: invalid regular expression: %v", err)
+		}
+		if re.NumSubexp() == 0 {
+			die("Error: the regular expression of the extraction needs at least one capture group")
 		}
+		return stateless(extractorFromRegex(re)), re.SubexpNames()[1:]
 	}
 
-	return cutterToSplitter(multiWsCutter)
+	return stateless(cutterToSplitter(multiWsCutter)), nil
 }
 
-func getSpans() []span {
+// getRecordSplit returns how the input is split into
+// the records which are then cut into fields.
+func getRecordSplit() bufio.SplitFunc {
+	if len(cutOnQuotedSeperatorArg.value) != 0 {
+		return quotedRecordSplit(cutOnQuotedSeperatorArg.value)
+	}
+	return bufio.ScanLines
+}
+
+// getSpans returns the spans to select with. Spans which use
+// field names are resolved against names, if these are known
+// already, otherwise against the first line of each input.
+func getSpans(names []string, tpl *template) []span {
+	if countValue(true, len(fieldsArg.value) != 0, len(charactersArg.value) != 0, len(bytesArg.value) != 0) > 1 {
+		die("You can only use one of fields, characters and bytes but you specified more than one")
+	}
+
+	if tpl != nil {
+		if countValue(true, len(fieldsArg.value) != 0, len(charactersArg.value) != 0, len(bytesArg.value) != 0, complementArg.value) != 0 {
+			die("Error: a template selects the fields itself and can not be used with fields, characters, bytes or the complement")
+		}
+		if hasNames(tpl.spans) && names != nil {
+			spans, err := resolveSpans(tpl.spans, names)
+			if err != nil {
+				die("Error: %v", err)
+			}
+			return spans
+		}
+		return tpl.spans
+	}
+
+	selection := fieldsArg.value
+	switch {
+	case len(charactersArg.value) != 0:
+		selection = charactersArg.value
+	case len(bytesArg.value) != 0:
+		selection = bytesArg.value
+	}
+
 	// get the spans either by default or user provided value
-	if len(fieldsArg.value) == 0 {
+	if len(selection) == 0 {
+		if complementArg.value {
+			die("Error: the complement can only be selected when fields are given")
+		}
 		return []span{{}}
 	}
 
-	spans, err := flagToSpans(fieldsArg.value, ",")
+	spans, err := flagToSpans(selection, ",")
 	if err != nil {
 		die("Error: %v\n", err)
 	}
 
+	if complementArg.value {
+		for _, s := range spans {
+			if s.sub != nil || s.chars != nil {
+				die("Error: the complement of sub-fields or characters can not be selected")
+			}
+		}
+	}
+
+	if hasNames(spans) && names != nil {
+		if spans, err = resolveSpans(spans, names); err != nil {
+			die("Error: %v", err)
+		}
+	}
+
+	return spans
+}
+
+// getOutputSeperator returns the output seperator, which by
+// default is empty when selecting characters or bytes.
+func getOutputSeperator() string {
+	if isSet(outputSeperatorArg) {
+		return outputSeperatorArg.value
+	}
+	if outputFormat(outputArg.value) == outputCSV {
+		return ","
+	}
+	if len(charactersArg.value) != 0 || len(bytesArg.value) != 0 {
+		return ""
+	}
+	return outputSeperatorArg.value
+}
+
+func getOutputFormat() outputFormat {
+	format := outputFormat(outputArg.value)
+	switch format {
+	case outputPlain, outputJSON, outputNDJSON, outputTSV, outputTable, outputBox, outputMarkdown:
+		return format
+	case outputCSV:
+		if utf8.RuneCountInString(getOutputSeperator()) != 1 {
+			die("Error: the output seperator has to be a single character for csv")
+		}
+		return format
+	}
+
+	die("Error: unknown output format '%s'", outputArg.value)
+	return format
+}
+
+// getTemplate returns the parsed template or nil if none is given.
+func getTemplate() *template {
+	if !isSet(templateArg) {
+		return nil
+	}
+	if isSet(outputArg) && outputFormat(outputArg.value) != outputPlain {
+		die("Error: a template can not be used with an output format")
+	}
+
+	tpl, err := parseTemplate(templateArg.value)
+	if err != nil {
+		die("Error: %v", err)
+	}
+	return tpl
+}
+
+// getOffsets reports whether the offsets are written,
+// which can only be done as JSON or TSV.
+func getOffsets() bool {
+	if !offsetsArg.value {
+		return false
+	}
+	if isSet(templateArg) {
+		die("Error: the offsets can not be written into a template")
+	}
+	switch outputFormat(outputArg.value) {
+	case outputJSON, outputNDJSON, outputTSV:
+		return true
+	}
+	die("Error: the offsets can only be written as json, ndjson or tsv")
+	return false
+}
+
+// getHighlight reports whether the lines are written
+// with the selected fields highlighted.
+func getHighlight() bool {
+	if !highlightArg.value {
+		return false
+	}
+	if isSet(templateArg) || offsetsArg.value || (isSet(outputArg) && outputFormat(outputArg.value) != outputPlain) {
+		die("Error: highlighting can not be used with a template, the offsets or an output format")
+	}
+	return true
+}
+
+// getColor reports whether the output is colored, which by
+// default is done when writing to a terminal and NO_COLOR
+// is not set.
+func getColor() bool {
+	switch colorMode(colorArg.value) {
+	case colorAlways:
+		return true
+	case colorNever:
+		return false
+	case colorAuto:
+		return isTerminal(os.Stdout) && len(os.Getenv("NO_COLOR")) == 0
+	}
+
+	die("Error: unknown color mode '%s'", colorArg.value)
+	return false
+}
+
+func getInspectCount() int {
+	if inspectCountArg.value <= 0 {
+		die("Error: the number of records to inspect has to be positive")
+	}
+	return inspectCountArg.value
+}
+
+// getPredicates returns the parsed predicates, which are resolved
+// if the names of the fields are known already.
+func getPredicates(names []string) []predicate {
+	predicates := make([]predicate, 0, len(whereArg.value))
+	for _, where := range whereArg.value {
+		p, err := parseWhere(where)
+		if err != nil {
+			die("Error: %v", err)
+		}
+		predicates = append(predicates, p)
+	}
+
+	if hasNames(predicateSpans(predicates)) && names != nil {
+		var err error
+		if predicates, err = resolvePredicates(predicates, names); err != nil {
+			die("Error: %v", err)
+		}
+	}
+	return predicates
+}
+
+// getLines returns the spans selecting the lines
+// of each input or nil if all lines are selected.
+func getLines() []span {
+	if len(linesArg.value) == 0 {
+		return nil
+	}
+
+	spans, err := flagToSpans(linesArg.value, ",")
+	if err == nil {
+		_, err = newLineSelector(spans)
+	}
+	if err != nil {
+		die("Error: %v", err)
+	}
 	return spans
 }
 
+// getMissing returns the value missing fields are filled
+// with or nil if they are left out.
+func getMissing() *string {
+	if !isSet(missingArg) {
+		return nil
+	}
+	if strictArg.value || complementArg.value {
+		die("Error: missing fields can not be filled in strict mode or with the complement")
+	}
+	return &missingArg.value
+}
+
+// getDelimited reports whether the lines which were not cut are left
+// out, and where these are written to instead. An extracted line counts
+// as cut if it matched.
+func getDelimited() (bool, io.Writer) {
+	if !onlyDelimitedArg.value && !isSet(undelimitedArg) {
+		return false, nil
+	}
+
+	if len(cutOnFixedWidthsArg.value) != 0 || cutOnHeaderArg.value || len(charactersArg.value) != 0 || len(bytesArg.value) != 0 {
+		die("Error: lines without delimiters can only be left out when cutting on delimiters")
+	}
+
+	switch undelimitedArg.value {
+	case "":
+		return true, nil
+	case "-":
+		return true, os.Stderr
+	}
+
+	file, err := os.Create(filepath.Clean(undelimitedArg.value))
+	if err != nil {
+		die("The file '%s' could not be opened for writing!", undelimitedArg.value)
+	}
+	return true, file
+}
+
+func getTableSample() int {
+	if tableSampleArg.value < 0 {
+		die("Error: the table sample can not be negative")
+	}
+	return tableSampleArg.value
+}
+
+func getHeaderMode() headerMode {
+	mode := headerMode(headerArg.value)
+	switch mode {
+	case headerNone, headerKeep, headerOnce, headerSkip:
+		return mode
+	}
+
+	die("Error: unknown header mode '%s'", headerArg.value)
+	return mode
+}
+
+// getOrder returns the order of the fields selected by the spans. In
+// the order of the input the fields are selected once each, so that
+// sub-fields, characters or filled in fields can not be selected.
+func getOrder(spans []span) fieldOrder {
+	order := fieldOrder(orderArg.value)
+	switch order {
+	case orderSpec:
+		return order
+	case orderInput:
+		if isSet(templateArg) || isSet(missingArg) {
+			die("Error: the fields can not be in the order of the input with a template or filled in fields")
+		}
+		for _, s := range spans {
+			if s.sub != nil || s.chars != nil {
+				die("Error: sub-fields or characters can not be selected in the order of the input")
+			}
+		}
+		return order
+	}
+
+	die("Error: unknown order '%s'", orderArg.value)
+	return order
+}
+
 func getReaders() []io.Reader {
 	files := flag.Args()
 
@@ -175,37 +746,192 @@ func getReaders() []io.Reader {
 	return readers
 }
 
-func do(writer io.Writer, readers []io.Reader, oSep string, spans []span, chunker StringSplitter) {
+func do(writer io.Writer, readers []io.Reader, opts options) {
+	out := newRecordWriter(writer, opts)
+	// with -hdr once only the first header is written,
+	// which is not the one of an empty input
+	headerWritten := false
 	for _, reader := range readers {
-		lineScanner := bufio.NewScanner(reader)
-		for lineScanner.Scan() {
-			isFirstPart := true // one can not know in advance what the last one will be
-			parts := chunker(lineScanner.Text())
-			for _, span := range spans {
-				for _, selected := range access(span, parts) {
-					if !isFirstPart {
-						io.WriteString(writer, oSep)
+		chunker := opts.newChunker()
+		resolvedSpans := opts.spans
+		resolvedWhere := opts.where
+		names := opts.names
+		isFirstLine := true
+
+		// write writes the columns selected from a line, which is
+		// the header of the input or one of its records
+		write := func(lineNumber int, line string, parts record, isHeader bool) {
+			if s, missing := unsatisfiedSpan(resolvedSpans, len(parts.fields)); missing && opts.strict {
+				die("Error: %s:%d: the fields '%s' do not exist in a line with %d fields", readerName(reader), lineNumber, formatSpan(s), len(parts.fields))
+			}
+
+			var selected []column
+			switch {
+			case opts.complement:
+				selected = complement(resolvedSpans, parts)
+			case opts.missing != nil:
+				selected = fillMissing(resolvedSpans, parts, *opts.missing)
+			case opts.order == orderInput:
+				selected = selectInOrder(resolvedSpans, parts)
+			default:
+				selected = selectFields(resolvedSpans, parts)
+			}
+			if opts.keepSeps {
+				selected = keepSeperators(parts, selected)
+			}
+			if opts.offsets || opts.highlight {
+				locateColumns(lineNumber, parts, selected)
+			}
+			if opts.highlight {
+				if opts.color {
+					line = highlight(line, selected)
+				}
+				selected = []column{{value: line}}
+			}
+
+			var err error
+			if isHeader {
+				err = out.writeHeader(selected)
+			} else {
+				err = out.write(selected, names)
+			}
+			if err != nil {
+				die("An error occured during writing: %v", err)
+			}
+		}
+
+		// the first line of each input is always cut, whether it is
+		// selected or not, as the names of the fields are looked up
+		// in it and -ch takes the columns from it. With a header it
+		// is always the header and never one of the records.
+		first := func(line string) {
+			parts := chunker(line)
+
+			if hasNames(opts.spans) {
+				var err error
+				if resolvedSpans, err = resolveSpans(opts.spans, parts.fields); err != nil {
+					die("Error: %v", err)
+				}
+			}
+			if hasNames(predicateSpans(opts.where)) {
+				var err error
+				if resolvedWhere, err = resolvePredicates(opts.where, parts.fields); err != nil {
+					die("Error: %v", err)
+				}
+			}
+
+			if opts.header == headerNone {
+				return
+			}
+			names = parts.fields
+			if opts.header == headerSkip || (opts.header == headerOnce && headerWritten) {
+				return
+			}
+			write(1, line, parts, true)
+			headerWritten = true
+		}
+
+		process := func(lineNumber int, line string) {
+			// the header was already handled by first
+			if lineNumber == 1 && opts.header != headerNone {
+				return
+			}
+
+			parts := chunker(line)
+			if opts.onlyDelimited && !parts.found {
+				if opts.undelimited != nil {
+					if _, err := io.WriteString(opts.undelimited, line+"\n"); err != nil {
+						die("An error occured during writing: %v", err)
 					}
-					io.WriteString(writer, selected)
-					isFirstPart = false
 				}
+				return
+			}
+
+			if matchesAll(resolvedWhere, parts) {
+				write(lineNumber, line, parts, false)
 			}
+		}
+
+		// without a line selection all lines are selected
+		lines, _ := newLineSelector([]span{{}})
+		if opts.lines != nil {
+			lines, _ = newLineSelector(opts.lines)
+		}
 
-			io.WriteString(writer, "\n")
+		lineScanner := bufio.NewScanner(reader)
+		lineScanner.Split(opts.split)
+		for !lines.done() && lineScanner.Scan() {
+			if isFirstLine {
+				first(lineScanner.Text())
+				isFirstLine = false
+			}
+			for _, line := range lines.push(lineScanner.Text()) {
+				process(line.number, line.text)
+			}
 		}
 
 		if lineScanner.Err() != nil {
 			die("An error occured during reading: %v", lineScanner.Err())
 		}
+
+		for _, line := range lines.flush() {
+			process(line.number, line.text)
+		}
 	}
+
+	if err := out.close(); err != nil {
+		die("An error occured during writing: %v", err)
+	}
+}
+
+// readerName returns the name of the file which is read
+// by the reader to tell the user where something is.
+func readerName(reader io.Reader) string {
+	if r, ok := reader.(AutoCloseReader); ok {
+		if file, ok := r.r.(*os.File); ok {
+			return file.Name()
+		}
+	}
+	return "standard input"
 }
 
 func main() {
 	setupFlags()
 
-	gutter := getGutter()
-	spans := getSpans()
+	gutter, names := getGutter()
+	tpl := getTemplate()
+	spans := getSpans(names, tpl)
 	readers := getReaders()
 
-	do(os.Stdout, readers, outputSeperatorArg.value, spans, gutter)
+	opts := options{
+		split:       getRecordSplit(),
+		newChunker:  gutter,
+		spans:       spans,
+		oSep:        getOutputSeperator(),
+		header:      getHeaderMode(),
+		complement:  complementArg.value,
+		order:       getOrder(spans),
+		keepSeps:    keepSeperatorsArg.value,
+		names:       names,
+		output:      getOutputFormat(),
+		tableSample: getTableSample(),
+		template:    tpl,
+		offsets:     getOffsets(),
+		highlight:   getHighlight(),
+		color:       getColor(),
+		where:       getPredicates(names),
+		lines:       getLines(),
+		strict:      strictArg.value,
+		missing:     getMissing(),
+	}
+	opts.onlyDelimited, opts.undelimited = getDelimited()
+
+	if inspectArg.value {
+		if err := inspect(os.Stdout, readers, opts, getInspectCount()); err != nil {
+			die("An error occured during inspecting: %v", err)
+		}
+		return
+	}
+
+	do(os.Stdout, readers, opts)
 }
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDoOnlyDelimited(t *testing.T) {
+	test := func(input string, splitter StringSplitter, onlyDelimited bool, expected, expectedUndelimited string) {
+		var out, undelimited strings.Builder
+		do(&out, []io.Reader{strings.NewReader(input)}, options{
+			split:         bufio.ScanLines,
+			newChunker:    stateless(splitter),
+			spans:         []span{{}},
+			oSep:          " ",
+			header:        headerNone,
+			onlyDelimited: onlyDelimited,
+			undelimited:   &undelimited,
+		})
+
+		if out.String() != expected || undelimited.String() != expectedUndelimited {
+			t.Errorf("Expected (%q, %q) Got (%q, %q)", expected, expectedUndelimited, out.String(), undelimited.String())
+		}
+	}
+
+	commas := cutterToSplitter(cutterFromSeperator(","))
+
+	test("a,b\nc\n,\n", commas, false, "a b\nc\n \n", "")
+	test("a,b\nc\n,\n", commas, true, "a b\n \n", "c\n")
+	test("c\n", commas, true, "", "c\n")
+
+	// a cut is known from the cutters and not from the number of fields
+	extract := extractorFromRegex(regexp.MustCompile(`(\d+)?ms`))
+	test("ms\n12ms\nx\n", extract, true, "\n12\n", "x\n")
+
+	// the format cutters cut as long as they find their seperator
+	format := func(s string) record {
+		return cutWithCutters(s, []StringCutter{cutterFromSeperator(","), cutterFromSeperator(";")})
+	}
+	test("a,b;c\na,b\nc\n", format, true, "a b c\na b\n", "c\n")
+}
+
+func TestDoLines(t *testing.T) {
+	test := func(input string, newChunker func() StringSplitter, header headerMode, fields, lines string, expected string) {
+		spans, _ := flagToSpans(fields, ",")
+		lineSpans, _ := flagToSpans(lines, ",")
+		var out strings.Builder
+		do(&out, []io.Reader{strings.NewReader(input)}, options{
+			split:      bufio.ScanLines,
+			newChunker: newChunker,
+			spans:      spans,
+			oSep:       "|",
+			header:     header,
+			lines:      lineSpans,
+		})
+
+		if out.String() != expected {
+			t.Errorf("Expected (%q) Got (%q) For (%s) With (%s)", expected, out.String(), fields, lines)
+		}
+	}
+
+	spaces := stateless(cutterToSplitter(singleWsCutter))
+
+	// the header is the first line whether it is selected or not
+	test("h\n1\n2\n", spaces, headerKeep, "", "-1:", "h\n2\n")
+	test("h\n1\n2\n", spaces, headerKeep, "", "2", "h\n1\n")
+	test("h\n1\n2\n", spaces, headerKeep, "", ":", "h\n1\n2\n")
+	test("h\n1\n2\n", spaces, headerSkip, "", "-3:", "1\n2\n")
+	test("h\n1\n2\n", spaces, headerNone, "", "-1:", "2\n")
+
+	// names are looked up in the first line even if it is not selected
+	test("a b\n1 2\n3 4\n", spaces, headerNone, "b", "3", "4\n")
+	test("a b\n1 2\n3 4\n", spaces, headerKeep, "b", "-1", "b\n4\n")
+
+	// the columns are the ones of the first line even if it is not selected
+	table := "NAME   IMAGE   PORTS\nweb    nginx\ndb     redis   80/tcp\n"
+	test(table, newHeaderSplitter, headerNone, "", "2:", "web|nginx|\ndb|redis|80/tcp\n")
+	test(table, newHeaderSplitter, headerKeep, "", "-1", "NAME|IMAGE|PORTS\ndb|redis|80/tcp\n")
+}
+
+func TestDoNames(t *testing.T) {
+	test := func(inputs []string, fields string, expected string) {
+		spans, err := flagToSpans(fields, ",")
+		if err != nil {
+			t.Errorf("Expected (%q) Got error (%v)", expected, err)
+			return
+		}
+		readers := make([]io.Reader, len(inputs))
+		for i, input := range inputs {
+			readers[i] = strings.NewReader(input)
+		}
+
+		var out strings.Builder
+		do(&out, readers, options{
+			split:      bufio.ScanLines,
+			newChunker: stateless(cutterToSplitter(singleWsCutter)),
+			spans:      spans,
+			oSep:       "|",
+			header:     headerSkip,
+		})
+
+		if out.String() != expected {
+			t.Errorf("Expected (%q) Got (%q) For (%s)", expected, out.String(), fields)
+		}
+	}
+
+	// the names are looked up in the header of each input
+	test([]string{"a b c\n1 2 3\n", "c a b\n3 1 2\n"}, "a,c", "1|3\n1|3\n")
+	test([]string{"a b c\n1 2 3\n", "b c\n2 3\n"}, "b:c", "2|3\n2|3\n")
+
+	// names can be mixed with numbers and negative numbers
+	test([]string{"a b c d\n1 2 3 4\n"}, "b:-1,1,-2:d", "2|3|4|1|3|4\n")
+	test([]string{"a b c d\n1 2 3 4\n", "x a b\n0 1 2\n"}, "1:a,b", "1|2\n0|1|2\n")
+	test([]string{"a b c d\n1 2 3 4\n"}, "d:b:-1,-1", "4|3|2|4\n")
+}
+
+func TestDoHeader(t *testing.T) {
+	test := func(inputs []string, header headerMode, expected string) {
+		readers := make([]io.Reader, len(inputs))
+		for i, input := range inputs {
+			readers[i] = strings.NewReader(input)
+		}
+
+		var out strings.Builder
+		do(&out, readers, options{
+			split:      bufio.ScanLines,
+			newChunker: stateless(cutterToSplitter(singleWsCutter)),
+			spans:      []span{{}},
+			oSep:       "|",
+			header:     header,
+		})
+
+		if out.String() != expected {
+			t.Errorf("Expected (%q) Got (%q) For (%s)", expected, out.String(), header)
+		}
+	}
+
+	inputs := []string{"a b\n1 2\n", "a b\n3 4\n"}
+	test(inputs, headerNone, "a|b\n1|2\na|b\n3|4\n")
+	test(inputs, headerKeep, "a|b\n1|2\na|b\n3|4\n")
+	test(inputs, headerSkip, "1|2\n3|4\n")
+	test(inputs, headerOnce, "a|b\n1|2\n3|4\n")
+
+	// an input with only a header has no records
+	test([]string{"a b\n", "a b\n3 4\n"}, headerOnce, "a|b\n3|4\n")
+	test([]string{"a b\n", "a b\n3 4\n"}, headerSkip, "3|4\n")
+
+	// an empty input has no header
+	empty := []string{"", "a b\n1 2\n", "a b\n3 4\n"}
+	test(empty, headerKeep, "a|b\n1|2\na|b\n3|4\n")
+	test(empty, headerSkip, "1|2\n3|4\n")
+	test(empty, headerOnce, "a|b\n1|2\n3|4\n")
+}
+
+func TestDoMissingKeys(t *testing.T) {
+	test := func(input string, opts options, fields, expected string) {
+		opts.spans, _ = flagToSpans(fields, ",")
+		opts.split = bufio.ScanLines
+		opts.newChunker = stateless(cutterToSplitter(singleWsCutter))
+		missing := "X"
+		opts.missing = &missing
+
+		var out strings.Builder
+		do(&out, []io.Reader{strings.NewReader(input)}, opts)
+		if out.String() != expected {
+			t.Errorf("Expected (%q) Got (%q) For (%s)", expected, out.String(), fields)
+		}
+	}
+
+	// filled in fields are keyed by the bound which selected them
+	test("h1 h2\na b\n", options{header: headerKeep, output: outputNDJSON}, "-5,1,4", "{\"-5\":\"X\",\"h1\":\"a\",\"4\":\"X\"}\n")
+	test("h1 h2\na b\n", options{header: headerKeep, output: outputNDJSON}, "-3:", "{\"-3\":\"X\",\"h1\":\"a\",\"h2\":\"b\"}\n")
+	test("a b\n", options{header: headerNone, output: outputNDJSON, offsets: true}, "-5",
+		"{\"line\":1,\"field\":-5,\"start\":-1,\"end\":-1,\"runeStart\":-1,\"runeEnd\":-1,\"value\":\"X\"}\n")
+}
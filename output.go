@@ -0,0 +1,508 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A recordWriter writes the columns selected
+// from each record in a specific output format.
+type recordWriter interface {
+	// writeHeader writes the columns selected from a header line.
+	writeHeader(columns []column) error
+	// write writes the columns selected from a record. The names
+	// are the names of all fields of the record, if they are known.
+	write(columns []column, names []string) error
+	// close finishes the output after the last record.
+	close() error
+}
+
+// newRecordWriter creates the recordWriter for the output format.
+func newRecordWriter(w io.Writer, opts options) recordWriter {
+	if opts.template != nil {
+		return &templateWriter{w: w, t: opts.template, sep: opts.oSep}
+	}
+	if opts.offsets {
+		return &offsetsWriter{w: w, format: opts.output}
+	}
+
+	switch opts.output {
+	case outputJSON:
+		return &jsonWriter{w: w}
+	case outputNDJSON:
+		return &jsonWriter{w: w, lines: true}
+	case outputCSV:
+		c := csv.NewWriter(w)
+		c.Comma, _ = utf8.DecodeRuneInString(opts.oSep)
+		return &csvWriter{c}
+	case outputTSV:
+		return &tsvWriter{w}
+	case outputTable, outputBox, outputMarkdown:
+		return &tableWriter{w: w, style: opts.output, sample: opts.tableSample}
+	}
+	return &plainWriter{w: w, sep: opts.oSep}
+}
+
+// plainWriter writes the values of each record seperated
+// by the output seperator on their own line.
+type plainWriter struct {
+	w   io.Writer
+	sep string
+}
+
+func (p *plainWriter) writeHeader(columns []column) error {
+	return p.write(columns, nil)
+}
+
+func (p *plainWriter) write(columns []column, _ []string) error {
+	var b strings.Builder
+	for i, c := range columns {
+		if i != 0 {
+			b.WriteString(p.sep)
+		}
+		b.WriteString(c.value)
+	}
+	b.WriteByte('\n')
+
+	_, err := io.WriteString(p.w, b.String())
+	return err
+}
+
+func (p *plainWriter) close() error {
+	return nil
+}
+
+// csvWriter writes the values of each record as a line
+// of a CSV file (RFC 4180), quoting the values if needed.
+type csvWriter struct {
+	c *csv.Writer
+}
+
+func (c *csvWriter) writeHeader(columns []column) error {
+	return c.write(columns, nil)
+}
+
+func (c *csvWriter) write(columns []column, _ []string) error {
+	if err := c.c.Write(values(columns)); err != nil {
+		return err
+	}
+	// flushing each line keeps the output streaming
+	c.c.Flush()
+	return c.c.Error()
+}
+
+func (c *csvWriter) close() error {
+	return nil
+}
+
+// tsvWriter writes the values of each record seperated by tabs.
+// Tabs, line breaks and backslashes in the values are escaped
+// as \t, \n, \r and \\.
+type tsvWriter struct {
+	w io.Writer
+}
+
+var tsvEscaper = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+
+func (t *tsvWriter) writeHeader(columns []column) error {
+	return t.write(columns, nil)
+}
+
+func (t *tsvWriter) write(columns []column, _ []string) error {
+	var b strings.Builder
+	for i, c := range columns {
+		if i != 0 {
+			b.WriteByte('\t')
+		}
+		tsvEscaper.WriteString(&b, c.value)
+	}
+	b.WriteByte('\n')
+
+	_, err := io.WriteString(t.w, b.String())
+	return err
+}
+
+func (t *tsvWriter) close() error {
+	return nil
+}
+
+// tableWriter aligns the values of the records in columns like
+// column -t does, optionally drawing a box around them or writing
+// them as a Markdown table. To know the widths of the columns the
+// records are buffered until the end, or only the first sample ones
+// if sample is not 0, so that the following ones can be streamed.
+// Those may not fit into the columns though.
+type tableWriter struct {
+	w      io.Writer
+	style  outputFormat
+	sample int
+	header []string
+	rows   [][]string
+	widths []int
+	// streaming is true once the widths are known
+	streaming bool
+}
+
+var markdownEscaper = strings.NewReplacer("|", "\\|", "\n", "<br>")
+
+func (t *tableWriter) writeHeader(columns []column) error {
+	// only the first header is the header of the table
+	if t.header != nil || t.streaming || len(t.rows) != 0 {
+		return t.write(columns, nil)
+	}
+	t.header = t.cells(values(columns))
+	return nil
+}
+
+func (t *tableWriter) write(columns []column, names []string) error {
+	// a Markdown table always needs a header
+	if t.style == outputMarkdown && t.header == nil && !t.streaming && len(t.rows) == 0 {
+		t.header = t.cells(columnKeys(columns, names))
+	}
+
+	row := t.cells(values(columns))
+	if t.streaming {
+		_, err := io.WriteString(t.w, t.formatRow(row))
+		return err
+	}
+
+	t.rows = append(t.rows, row)
+	if t.sample > 0 && len(t.rows) >= t.sample {
+		return t.flush()
+	}
+	return nil
+}
+
+func (t *tableWriter) close() error {
+	if !t.streaming {
+		if err := t.flush(); err != nil {
+			return err
+		}
+	}
+
+	if t.style == outputBox && (t.header != nil || len(t.widths) != 0) {
+		_, err := io.WriteString(t.w, t.rule("└", "┴", "┘"))
+		return err
+	}
+	return nil
+}
+
+// cells escapes the values so that they can be put into the table.
+func (t *tableWriter) cells(values []string) []string {
+	if t.style == outputMarkdown {
+		for i, v := range values {
+			values[i] = markdownEscaper.Replace(v)
+		}
+	}
+	return values
+}
+
+// flush computes the widths of the columns from the buffered
+// rows and writes them, so that all following rows are streamed.
+func (t *tableWriter) flush() error {
+	t.streaming = true
+	for _, row := range append([][]string{t.header}, t.rows...) {
+		for i, cell := range row {
+			if i == len(t.widths) {
+				t.widths = append(t.widths, 0)
+			}
+			if width := displayWidth(cell); width > t.widths[i] {
+				t.widths[i] = width
+			}
+		}
+	}
+
+	if t.style == outputMarkdown {
+		// the seperator line needs at least three dashes
+		for i, width := range t.widths {
+			if width < 3 {
+				t.widths[i] = 3
+			}
+		}
+	}
+
+	var b strings.Builder
+	if t.style == outputBox && (t.header != nil || len(t.widths) != 0) {
+		b.WriteString(t.rule("┌", "┬", "┐"))
+	}
+	if t.header != nil {
+		b.WriteString(t.formatRow(t.header))
+		switch t.style {
+		case outputBox:
+			b.WriteString(t.rule("├", "┼", "┤"))
+		case outputMarkdown:
+			b.WriteString(t.rule("|", "|", "|"))
+		}
+	}
+	for _, row := range t.rows {
+		b.WriteString(t.formatRow(row))
+	}
+	t.rows = nil
+
+	_, err := io.WriteString(t.w, b.String())
+	return err
+}
+
+// formatRow pads the cells to the widths of the columns.
+func (t *tableWriter) formatRow(row []string) string {
+	n := len(t.widths)
+	if len(row) > n {
+		n = len(row)
+	}
+
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		cell, width := "", 0
+		if i < len(row) {
+			cell = row[i]
+		}
+		if i < len(t.widths) {
+			width = t.widths[i]
+		}
+		padding := ""
+		if fill := width - displayWidth(cell); fill > 0 {
+			padding = strings.Repeat(" ", fill)
+		}
+
+		switch t.style {
+		case outputBox, outputMarkdown:
+			border := "│"
+			if t.style == outputMarkdown {
+				border = "|"
+			}
+			if i == 0 {
+				b.WriteString(border)
+			}
+			b.WriteString(" " + cell + padding + " " + border)
+		default:
+			if i != 0 {
+				b.WriteString("  ")
+			}
+			b.WriteString(cell + padding)
+		}
+	}
+
+	// the padding of the last columns is not needed
+	if t.style == outputTable {
+		return strings.TrimRight(b.String(), " ") + "\n"
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// rule returns a horizontal line seperating rows, which starts,
+// ends and crosses the columns with the given characters.
+func (t *tableWriter) rule(start, cross, end string) string {
+	line := "─"
+	if t.style == outputMarkdown {
+		line = "-"
+	}
+
+	var b strings.Builder
+	b.WriteString(start)
+	for i, width := range t.widths {
+		if i != 0 {
+			b.WriteString(cross)
+		}
+		b.WriteString(strings.Repeat(line, width+2))
+	}
+	b.WriteString(end)
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// jsonWriter writes each record as a JSON array of the values or
+// as an object when the names of the fields are known. The records
+// are either all put into one JSON array or, with lines, each is
+// written on its own line (NDJSON). A header is not written as it
+// only names the fields.
+type jsonWriter struct {
+	w       io.Writer
+	lines   bool
+	written bool
+}
+
+func (j *jsonWriter) writeHeader(_ []column) error {
+	return nil
+}
+
+func (j *jsonWriter) write(columns []column, names []string) error {
+	var b strings.Builder
+	switch {
+	case j.lines:
+	case !j.written:
+		b.WriteString("[\n")
+	default:
+		b.WriteString(",\n")
+	}
+	j.written = true
+
+	if !hasNamedField(names) {
+		b.WriteByte('[')
+		for i, c := range columns {
+			if i != 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(jsonString(c.value))
+		}
+		b.WriteByte(']')
+	} else {
+		b.WriteByte('{')
+		for i, key := range columnKeys(columns, names) {
+			if i != 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(jsonString(key))
+			b.WriteByte(':')
+			b.WriteString(jsonString(columns[i].value))
+		}
+		b.WriteByte('}')
+	}
+
+	if j.lines {
+		b.WriteByte('\n')
+	}
+
+	_, err := io.WriteString(j.w, b.String())
+	return err
+}
+
+func (j *jsonWriter) close() error {
+	var err error
+	switch {
+	case j.lines:
+	case !j.written:
+		_, err = io.WriteString(j.w, "[]\n")
+	default:
+		_, err = io.WriteString(j.w, "\n]\n")
+	}
+	return err
+}
+
+// offsetsWriter writes the position of each selected value instead
+// of the record, which is its line number, the 1-based index of its
+// field and the start and end offsets of its text in bytes and runes.
+// They are written as a JSON object for each value or as tab seperated
+// values in the order line, field, start, end, rune start, rune end and
+// value. Values which are not part of the line have the offsets -1.
+type offsetsWriter struct {
+	w       io.Writer
+	format  outputFormat
+	written bool
+}
+
+func (o *offsetsWriter) writeHeader(columns []column) error {
+	return o.write(columns, nil)
+}
+
+func (o *offsetsWriter) write(columns []column, _ []string) error {
+	var b strings.Builder
+	for _, c := range columns {
+		offsets := []int{c.line, c.idx + 1, c.start, c.end, c.runeStart, c.runeEnd}
+
+		if o.format == outputTSV {
+			for _, offset := range offsets {
+				b.WriteString(strconv.Itoa(offset))
+				b.WriteByte('\t')
+			}
+			tsvEscaper.WriteString(&b, c.value)
+			b.WriteByte('\n')
+			continue
+		}
+
+		switch {
+		case o.format == outputNDJSON:
+		case !o.written:
+			b.WriteString("[\n")
+		default:
+			b.WriteString(",\n")
+		}
+		o.written = true
+
+		b.WriteByte('{')
+		for i, key := range []string{"line", "field", "start", "end", "runeStart", "runeEnd"} {
+			b.WriteString(jsonString(key))
+			b.WriteByte(':')
+			b.WriteString(strconv.Itoa(offsets[i]))
+			b.WriteByte(',')
+		}
+		b.WriteString(`"value":`)
+		b.WriteString(jsonString(c.value))
+		b.WriteByte('}')
+
+		if o.format == outputNDJSON {
+			b.WriteByte('\n')
+		}
+	}
+
+	_, err := io.WriteString(o.w, b.String())
+	return err
+}
+
+func (o *offsetsWriter) close() error {
+	if o.format != outputJSON {
+		return nil
+	}
+	out := "\n]\n"
+	if !o.written {
+		out = "[]\n"
+	}
+	_, err := io.WriteString(o.w, out)
+	return err
+}
+
+// values returns the values of the columns.
+func values(columns []column) []string {
+	result := make([]string, len(columns))
+	for i, c := range columns {
+		result[i] = c.value
+	}
+	return result
+}
+
+// hasNamedField reports whether any of the names is not empty.
+func hasNamedField(names []string) bool {
+	for _, name := range names {
+		if len(name) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// columnKeys returns a unique key for each column, which is the
+// name of the field it is from or its 1-based index if the field
+// has no name. Repeated keys get the number of the repetition
+// appended like NAME_2.
+func columnKeys(columns []column, names []string) []string {
+	keys := make([]string, len(columns))
+	seen := make(map[string]int, len(columns))
+	for i, c := range columns {
+		key := strconv.Itoa(c.idx + 1)
+		if c.idx >= 0 && c.idx < len(names) && len(names[c.idx]) != 0 {
+			key = names[c.idx]
+		}
+
+		seen[key]++
+		if seen[key] > 1 {
+			key += "_" + strconv.Itoa(seen[key])
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+// jsonString returns s as a JSON string.
+func jsonString(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	// encoding a string can not fail
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// columnsOf creates the columns as if all values were selected
+func columnsOf(values ...string) []column {
+	columns := make([]column, len(values))
+	for i, v := range values {
+		columns[i] = column{value: v, idx: i}
+	}
+	return columns
+}
+
+func TestPlainWriter(t *testing.T) {
+	var b strings.Builder
+	w := newRecordWriter(&b, options{oSep: ";"})
+
+	w.writeHeader(columnsOf("A", "B"))
+	w.write(columnsOf("a", "b"), []string{"A", "B"})
+	w.write(columnsOf(), nil)
+	w.write(columnsOf("c"), nil)
+	w.close()
+
+	expected := "A;B\na;b\n\nc\n"
+	if b.String() != expected {
+		t.Errorf("Expected (%q) Got (%q)", expected, b.String())
+	}
+}
+
+func TestCsvWriter(t *testing.T) {
+	test := func(sep string, expected string, records ...[]column) {
+		var b strings.Builder
+		w := newRecordWriter(&b, options{output: outputCSV, oSep: sep})
+		for _, r := range records {
+			w.write(r, nil)
+		}
+		w.close()
+
+		if b.String() != expected {
+			t.Errorf("Expected (%q) Got (%q)", expected, b.String())
+		}
+	}
+
+	test(",", "a,b\n", columnsOf("a", "b"))
+	test(",", "\"a,b\",c\n", columnsOf("a,b", "c"))
+	test(",", "\"a\"\"b\"\n", columnsOf(`a"b`))
+	test(",", "\"a\nb\"\n", columnsOf("a\nb"))
+	test(";", "a,b;c\n", columnsOf("a,b", "c"))
+	test(";", "\"a;b\";c\n", columnsOf("a;b", "c"))
+}
+
+func TestTsvWriter(t *testing.T) {
+	var b strings.Builder
+	w := newRecordWriter(&b, options{output: outputTSV, oSep: " "})
+	w.writeHeader(columnsOf("A", "B"))
+	w.write(columnsOf("a b", "c\td"), nil)
+	w.write(columnsOf("e\nf", `g\h`, "i\r"), nil)
+	w.close()
+
+	expected := "A\tB\na b\tc\\td\ne\\nf\tg\\\\h\ti\\r\n"
+	if b.String() != expected {
+		t.Errorf("Expected (%q) Got (%q)", expected, b.String())
+	}
+}
+
+func TestTableWriter(t *testing.T) {
+	test := func(opts options, write func(recordWriter), expected string) {
+		var b strings.Builder
+		w := newRecordWriter(&b, opts)
+		write(w)
+		w.close()
+
+		if b.String() != expected {
+			t.Errorf("Expected\n%s\nGot\n%s", expected, b.String())
+		}
+	}
+
+	records := func(w recordWriter) {
+		w.writeHeader(columnsOf("NAME", "AGE"))
+		w.write(columnsOf("bob", "42"), nil)
+		w.write(columnsOf("日本", "7", "x"), nil)
+	}
+
+	test(options{output: outputTable}, func(w recordWriter) {}, "")
+	test(options{output: outputBox}, func(w recordWriter) {}, "")
+
+	test(options{output: outputTable}, records, ""+
+		"NAME  AGE\n"+
+		"bob   42\n"+
+		"日本  7    x\n")
+
+	test(options{output: outputBox}, records, ""+
+		"┌──────┬─────┬───┐\n"+
+		"│ NAME │ AGE │   │\n"+
+		"├──────┼─────┼───┤\n"+
+		"│ bob  │ 42  │   │\n"+
+		"│ 日本 │ 7   │ x │\n"+
+		"└──────┴─────┴───┘\n")
+
+	test(options{output: outputMarkdown}, records, ""+
+		"| NAME | AGE |     |\n"+
+		"|------|-----|-----|\n"+
+		"| bob  | 42  |     |\n"+
+		"| 日本 | 7   | x   |\n")
+
+	// without a header the markdown table is headed by the names of the fields
+	test(options{output: outputMarkdown}, func(w recordWriter) {
+		w.write(columnsOf("a|b", "c"), []string{"A"})
+	}, ""+
+		"| A    | 2   |\n"+
+		"|------|-----|\n"+
+		"| a\\|b | c   |\n")
+
+	// only the sampled rows define the widths
+	test(options{output: outputTable, tableSample: 1}, func(w recordWriter) {
+		w.write(columnsOf("a", "b"), nil)
+		w.write(columnsOf("ccc", "d"), nil)
+	}, ""+
+		"a  b\n"+
+		"ccc  d\n")
+}
+
+func TestJsonWriter(t *testing.T) {
+	test := func(format outputFormat, write func(recordWriter), expected string) {
+		var b strings.Builder
+		w := newRecordWriter(&b, options{output: format})
+		write(w)
+		w.close()
+
+		if b.String() != expected {
+			t.Errorf("Expected (%q) Got (%q)", expected, b.String())
+		}
+	}
+
+	test(outputJSON, func(w recordWriter) {}, "[]\n")
+	test(outputNDJSON, func(w recordWriter) {}, "")
+
+	test(outputJSON, func(w recordWriter) {
+		w.write(columnsOf("a", "b"), nil)
+		w.write(columnsOf(`"<c>"`), nil)
+	}, "[\n[\"a\",\"b\"],\n[\"\\\"<c>\\\"\"]\n]\n")
+
+	test(outputNDJSON, func(w recordWriter) {
+		w.write(columnsOf("a", "b"), nil)
+		w.write(columnsOf(), nil)
+	}, "[\"a\",\"b\"]\n[]\n")
+
+	// names turn the records into objects and the header is not written
+	test(outputNDJSON, func(w recordWriter) {
+		w.writeHeader(columnsOf("A", "B"))
+		w.write(columnsOf("a", "b"), []string{"A", "B"})
+	}, "{\"A\":\"a\",\"B\":\"b\"}\n")
+
+	test(outputNDJSON, func(w recordWriter) {
+		w.write(columnsOf("a", "b"), []string{"", ""})
+	}, "[\"a\",\"b\"]\n")
+}
+
+func TestColumnKeys(t *testing.T) {
+	test := func(columns []column, names []string, expected string) {
+		if actual := strings.Join(columnKeys(columns, names), "|"); actual != expected {
+			t.Errorf("Expected (%s) Got (%s)", expected, actual)
+		}
+	}
+
+	test(columnsOf("a", "b"), []string{"A", "B"}, "A|B")
+	test(columnsOf("a", "b", "c"), []string{"A"}, "A|2|3")
+	test(columnsOf("a", "b"), []string{"", "B"}, "1|B")
+	test([]column{{idx: 1}, {idx: 0}, {idx: 1}, {idx: 1}}, []string{"A", "B"}, "B|A|B_2|B_3")
+}
+
+func TestOffsetsWriter(t *testing.T) {
+	test := func(format outputFormat, columns []column, expected string) {
+		var b strings.Builder
+		w := newRecordWriter(&b, options{output: format, offsets: true})
+		w.write(columns, nil)
+		w.close()
+
+		if b.String() != expected {
+			t.Errorf("Expected (%q) Got (%q)", expected, b.String())
+		}
+	}
+
+	columns := []column{
+		{value: "ä\tb", idx: 1, line: 2, start: 3, end: 7, runeStart: 2, runeEnd: 5},
+		{value: "c", idx: 2, line: 2, start: -1, end: -1, runeStart: -1, runeEnd: -1},
+	}
+
+	test(outputTSV, columns, "2\t2\t3\t7\t2\t5\tä\\tb\n2\t3\t-1\t-1\t-1\t-1\tc\n")
+	test(outputNDJSON, columns[1:], "{\"line\":2,\"field\":3,\"start\":-1,\"end\":-1,\"runeStart\":-1,\"runeEnd\":-1,\"value\":\"c\"}\n")
+	test(outputJSON, columns[1:], "[\n{\"line\":2,\"field\":3,\"start\":-1,\"end\":-1,\"runeStart\":-1,\"runeEnd\":-1,\"value\":\"c\"}\n]\n")
+	test(outputJSON, nil, "[]\n")
+}
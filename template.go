@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// the placeholders of a template are put between these
+const placeholderOpen, placeholderClose = '{', '}'
+
+// A template is literal text with placeholders in between, which
+// are replaced by the fields selected by their span. There is
+// always one more literal than there are spans, as the text can
+// start and end with a placeholder.
+type template struct {
+	literals []string
+	spans    []span
+}
+
+// parseTemplate parses a template like '{1}:{NAME} -> {-1}' where
+// each placeholder contains a single span of the FIELDS grammar.
+// Literal braces are written as '{{' and '}}'.
+func parseTemplate(s string) (*template, error) {
+	result := &template{}
+	var literal strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == placeholderOpen && i+1 < len(s) && s[i+1] == placeholderOpen:
+			literal.WriteByte(placeholderOpen)
+			i++
+		case s[i] == placeholderClose && i+1 < len(s) && s[i+1] == placeholderClose:
+			literal.WriteByte(placeholderClose)
+			i++
+		case s[i] == placeholderClose:
+			return nil, fmt.Errorf("the '%c' at %d in the template is never opened, use '%c%c' for a literal one", placeholderClose, i+1, placeholderClose, placeholderClose)
+		case s[i] == placeholderOpen:
+			end := placeholderEnd(s, i)
+			if end < 0 {
+				return nil, fmt.Errorf("the '%c' at %d in the template is never closed", placeholderOpen, i+1)
+			}
+
+			placeholder := s[i+1 : end]
+			if len(placeholder) == 0 {
+				return nil, errors.New("the template contains an empty placeholder")
+			}
+			sp, err := parseSpan(placeholder)
+			if err != nil {
+				return nil, err
+			}
+
+			result.literals = append(result.literals, literal.String())
+			result.spans = append(result.spans, sp)
+			literal.Reset()
+			i = end
+		default:
+			literal.WriteByte(s[i])
+		}
+	}
+
+	result.literals = append(result.literals, literal.String())
+	return result, nil
+}
+
+// placeholderEnd returns the index of the brace closing the placeholder
+// which is opened at start, ignoring braces inside of sub-selections.
+func placeholderEnd(s string, start int) int {
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case subSeperatorOpen:
+			end := strings.IndexByte(s[i:], subSeperatorClose)
+			if end < 0 {
+				return -1
+			}
+			i += end
+		case placeholderClose:
+			return i
+		}
+	}
+	return -1
+}
+
+// templateWriter writes each record by filling the placeholders of
+// the template with the columns that their span selected. Multiple
+// columns of a placeholder are seperated by the output seperator.
+type templateWriter struct {
+	w   io.Writer
+	t   *template
+	sep string
+}
+
+func (t *templateWriter) writeHeader(columns []column) error {
+	return t.write(columns, nil)
+}
+
+func (t *templateWriter) write(columns []column, _ []string) error {
+	var b strings.Builder
+	b.WriteString(t.t.literals[0])
+
+	next := 0
+	for spanIdx := range t.t.spans {
+		for first := true; next < len(columns) && columns[next].span == spanIdx; next++ {
+			if !first {
+				b.WriteString(t.sep)
+			}
+			b.WriteString(columns[next].value)
+			first = false
+		}
+		b.WriteString(t.t.literals[spanIdx+1])
+	}
+	b.WriteByte('\n')
+
+	_, err := io.WriteString(t.w, b.String())
+	return err
+}
+
+func (t *templateWriter) close() error {
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	testOk := func(s string, literals string, spans int) {
+		tpl, err := parseTemplate(s)
+		if err != nil {
+			t.Errorf("Expected (%s) Got error (%v) For (%s)", literals, err, s)
+			return
+		}
+		if actual := strings.Join(tpl.literals, "|"); actual != literals || len(tpl.spans) != spans {
+			t.Errorf("Expected (%s, %d) Got (%s, %d) For (%s)", literals, spans, actual, len(tpl.spans), s)
+		}
+	}
+	testFailed := func(s string) {
+		if _, err := parseTemplate(s); err == nil {
+			t.Errorf("Expected error For (%s)", s)
+		}
+	}
+
+	testOk("", "", 0)
+	testOk("abc", "abc", 0)
+	testOk("{1}", "|", 1)
+	testOk("{1}:{NAME} -> {-1}", "|:| -> |", 3)
+	testOk("a {{b}} {2:4}", "a {b} |", 1)
+	testOk("{1[}]2}", "|", 1)
+
+	testFailed("{}")
+	testFailed("{1")
+	testFailed("1}")
+	testFailed("{1[}")
+	testFailed("{a:b:c:d}")
+}
+
+func TestTemplateWriter(t *testing.T) {
+	test := func(s string, fields []string, expected string) {
+		tpl, err := parseTemplate(s)
+		if err != nil {
+			t.Errorf("Expected (%q) Got error (%v)", expected, err)
+			return
+		}
+
+		var b strings.Builder
+		w := newRecordWriter(&b, options{template: tpl, oSep: " "})
+		w.write(selectFields(tpl.spans, recordOf(fields...)), nil)
+		w.close()
+
+		if b.String() != expected {
+			t.Errorf("Expected (%q) Got (%q) For (%s)", expected, b.String(), s)
+		}
+	}
+
+	test("ssh -p {-1} {1}@{2}", []string{"bob", "host", "22"}, "ssh -p 22 bob@host\n")
+	test("{2:} and {1}{1}", []string{"a", "b", "c"}, "b c and aa\n")
+	test("[{4}]", []string{"a"}, "[]\n")
+	test("{1[.]-1}", []string{"x.y.z"}, "z\n")
+}
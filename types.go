@@ -1,6 +1,10 @@
 package main
 
-import "io"
+import (
+	"bufio"
+	"io"
+	"strings"
+)
 
 // struct that enables to use the flag
 // package with different aliases while
@@ -12,24 +16,183 @@ type arg[T any] struct {
 	defaultValue T
 }
 
+// listValue is a flag.Value which collects the
+// values of a flag that can be given many times
+type listValue []string
+
+func (l *listValue) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 // stores indecies which which a slice
 // can be accessed. Both left and right
 // inclusive and 1-index based, so that 0
-// the default value, stands for unspecified
+// the default value, stands for unspecified.
+// The step is the distance between the selected
+// indecies, where 0 stands for unspecified (1).
+// A bound can also be given by the name of a field
+// which has to be resolved into an index before use.
+// With a sub span the selected items are split again
+// on the sub seperator and selected from with it.
+// With a chars span only the characters it selects
+// are kept of each selected item.
 type span struct {
-	left, right int
+	left, right, step   int
+	leftName, rightName string
+	subSep              string
+	sub, chars          *span
 }
 
-// A StringCutter cuts a string into left, right and found.
-// It behaves like strings.Cut with the
-// seperation token being encapulated in the function
-// itself.
-type StringCutter func(string) (string, string, bool)
+// a column is a value that was selected from a record
+// together with the 0-based index of the field it is from
+// and the index of the span which selected it. A missing
+// field in front of the record has a negative index, so
+// that its number idx+1 is the one it was selected by. The start
+// and end are the byte offsets of the text of the value in
+// the record, which are -1 if the value is not part of it.
+// When the offsets are needed, the line is the 1-based
+// number of the record and runeStart and runeEnd are the
+// offsets in runes.
+type column struct {
+	value     string
+	idx       int
+	span      int
+	start     int
+	end       int
+	line      int
+	runeStart int
+	runeEnd   int
+}
+
+// A StringCutter cuts a string once at the seperation
+// token encapsulated in the function itself and returns
+// the cut, which tells where the field in front of the
+// seperator is in the string.
+type StringCutter func(string) cut
+
+// cut is what a StringCutter cut a string into: left is
+// the field in front of the seperator, right is the rest
+// of the string after it and found reports whether the
+// seperator was found at all. As left is not always the
+// text in front of the seperator as it is, like a trimmed
+// or unquoted field, start and end are the byte offsets of
+// the text left was taken from in the string.
+type cut struct {
+	left, right string
+	found       bool
+	start, end  int
+}
 
 // A StringSplitter cuts a string into multiple pieces.
 // Unlike the StringCutter that only performs one cut
 // a StringSplitter can perform multiple cuts.
-type StringSplitter func(string) []string
+type StringSplitter func(string) record
+
+// a record is a string cut into fields together with the
+// byte offsets of the text of each field in the string,
+// which are -1 for a field that is not part of it, like
+// a capture group that did not match. found reports if
+// the string was cut on a seperator at all, or if it
+// matched when the fields are extracted from it.
+type record struct {
+	text         string
+	fields       []string
+	starts, ends []int
+	found        bool
+}
+
+// options controls how the input is
+// processed and what is written out.
+type options struct {
+	// split splits the input into records
+	split bufio.SplitFunc
+	// newChunker creates the splitter used to
+	// cut the records of an input into fields
+	newChunker func() StringSplitter
+	spans      []span
+	complement bool
+	order      fieldOrder
+	// keepSeps keeps the original seperators
+	// between contiguous selected fields
+	keepSeps bool
+	oSep     string
+	header   headerMode
+	// names of the fields if they are known
+	// before reading the input
+	names  []string
+	output outputFormat
+	// number of records used to compute the
+	// widths of a table, 0 stands for all
+	tableSample int
+	// offsets writes the positions of the selected
+	// fields instead of the fields themselves
+	offsets bool
+	// highlight writes the lines as they are with
+	// the selected fields colored if color is set
+	highlight bool
+	color     bool
+	// strict fails on lines without the selected
+	// fields, which are filled with missing instead
+	// if it is set
+	strict  bool
+	missing *string
+	// onlyDelimited leaves out the records which
+	// were not cut and writes them to undelimited
+	// instead if it is set
+	onlyDelimited bool
+	undelimited   io.Writer
+	// lines are the spans selecting the lines
+	// of each input, nil selects all of them
+	lines []span
+	// where are the predicates all records
+	// have to fulfill to be written
+	where []predicate
+	// template the selected fields are written
+	// into instead of the output format
+	template *template
+}
+
+// headerMode defines how the first line
+// of each input is treated.
+type headerMode string
+
+const (
+	headerNone headerMode = "none"
+	headerKeep headerMode = "keep"
+	headerOnce headerMode = "once"
+	headerSkip headerMode = "skip"
+)
+
+// fieldOrder defines in which order
+// the selected fields are written.
+type fieldOrder string
+
+const (
+	// in the order of the spans, as often as selected
+	orderSpec fieldOrder = "spec"
+	// in the order of the record, each only once
+	orderInput fieldOrder = "input"
+)
+
+// outputFormat defines how the selected
+// fields are written out.
+type outputFormat string
+
+const (
+	outputPlain    outputFormat = "plain"
+	outputJSON     outputFormat = "json"
+	outputNDJSON   outputFormat = "ndjson"
+	outputCSV      outputFormat = "csv"
+	outputTSV      outputFormat = "tsv"
+	outputTable    outputFormat = "table"
+	outputBox      outputFormat = "box"
+	outputMarkdown outputFormat = "markdown"
+)
 
 // An AutoCloseReader is a reader that encapsulates
 // a ReadCloser. The AutoCloseReader closes the
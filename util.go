@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 )
@@ -10,6 +11,20 @@ func die(format string, stuff ...interface{}) {
 	os.Exit(1)
 }
 
+// isSet reports whether the arg was given on
+// the command line using any of its aliases.
+func isSet[T any](a arg[T]) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		for _, alias := range a.aliases {
+			if f.Name == alias {
+				set = true
+			}
+		}
+	})
+	return set
+}
+
 func countValue[T comparable](value T, items ...T) int {
 	counter := 0
 	for _, item := range items {
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// the operators of a predicate, where the longer ones come
+// first as they start like the shorter ones
+var predicateOperators = []string{"!~", "!=", "<=", ">=", "~", "=", "<", ">"}
+
+// A predicate is a condition on the fields selected by
+// its span, which holds if it holds for any of them.
+type predicate struct {
+	field   span
+	matches func(string) bool
+}
+
+// parseWhere parses a predicate like '3~^nginx' or '-1>100MB', which is
+// made up of a span, an operator and a value. The operators ~ and !~
+// match the value as a regular expression and = and != compare it as
+// a string. The operators <, <=, > and >= compare numbers, sizes like
+// 10MB or durations like 1h30m depending on what the value is.
+func parseWhere(s string) (predicate, error) {
+	opIdx, op := -1, ""
+	for i := 0; i < len(s) && opIdx < 0; i++ {
+		if s[i] == subSeperatorOpen {
+			end := strings.IndexByte(s[i:], subSeperatorClose)
+			if end < 0 {
+				break
+			}
+			i += end
+			continue
+		}
+		for _, candidate := range predicateOperators {
+			if strings.HasPrefix(s[i:], candidate) {
+				opIdx, op = i, candidate
+				break
+			}
+		}
+	}
+	if opIdx <= 0 {
+		return predicate{}, fmt.Errorf("the predicate '%s' is not like FIELD OP VALUE", s)
+	}
+
+	field, err := parseSpan(s[:opIdx])
+	if err != nil {
+		return predicate{}, err
+	}
+
+	matches, err := predicateMatcher(op, s[opIdx+len(op):])
+	if err != nil {
+		return predicate{}, fmt.Errorf("invalid predicate '%s': %v", s, err)
+	}
+	return predicate{field: field, matches: matches}, nil
+}
+
+// predicateMatcher returns the function which checks
+// a field against the value using the operator.
+func predicateMatcher(op string, value string) (func(string) bool, error) {
+	switch op {
+	case "~", "!~":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		negate := op == "!~"
+		return func(field string) bool {
+			return re.MatchString(field) != negate
+		}, nil
+	case "=":
+		return func(field string) bool { return field == value }, nil
+	case "!=":
+		return func(field string) bool { return field != value }, nil
+	}
+
+	// find out what is compared from the value
+	var parse func(string) (float64, bool)
+	for _, p := range []func(string) (float64, bool){parseNumber, parseDuration, parseSize} {
+		if _, ok := p(value); ok {
+			parse = p
+			break
+		}
+	}
+	if parse == nil {
+		return nil, fmt.Errorf("'%s' is neither a number, a duration nor a size", value)
+	}
+
+	expected, _ := parse(value)
+	return func(field string) bool {
+		field = strings.Trim(field, wsChars)
+		actual, ok := parse(field)
+		if !ok {
+			// plain numbers are taken as bytes or seconds
+			if actual, ok = parseNumber(field); !ok {
+				return false
+			}
+		}
+		switch op {
+		case "<":
+			return actual < expected
+		case "<=":
+			return actual <= expected
+		case ">":
+			return actual > expected
+		}
+		return actual >= expected
+	}, nil
+}
+
+// matchesAll reports whether all predicates hold for the record.
+func matchesAll(predicates []predicate, r record) bool {
+	for _, p := range predicates {
+		matched := false
+		for _, c := range selectFields([]span{p.field}, r) {
+			if p.matches(c.value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// predicateSpans returns the spans of the predicates.
+func predicateSpans(predicates []predicate) []span {
+	spans := make([]span, len(predicates))
+	for i, p := range predicates {
+		spans[i] = p.field
+	}
+	return spans
+}
+
+// resolvePredicates returns the predicates with the named
+// bounds of their spans resolved like resolveSpans does.
+func resolvePredicates(predicates []predicate, names []string) ([]predicate, error) {
+	spans, err := resolveSpans(predicateSpans(predicates), names)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]predicate, len(predicates))
+	for i, p := range predicates {
+		result[i] = predicate{field: spans[i], matches: p.matches}
+	}
+	return result, nil
+}
+
+func parseNumber(s string) (float64, bool) {
+	n, err := strconv.ParseFloat(s, 64)
+	return n, err == nil && !math.IsNaN(n) && !math.IsInf(n, 0)
+}
+
+// the factors of the units of durations in seconds
+var durationUnits = map[string]float64{
+	"ns": 1e-9,
+	"us": 1e-6,
+	"µs": 1e-6,
+	"ms": 1e-3,
+	"s":  1,
+	"m":  60,
+	"h":  60 * 60,
+	"d":  24 * 60 * 60,
+	"w":  7 * 24 * 60 * 60,
+}
+
+var durationPart = regexp.MustCompile(`(\d+(?:\.\d+)?)(ns|us|µs|ms|s|m|h|d|w)`)
+
+// parseDuration parses a duration like 1.5s or 1h30m into seconds.
+func parseDuration(s string) (float64, bool) {
+	parts := durationPart.FindAllStringSubmatchIndex(s, -1)
+	if len(parts) == 0 {
+		return 0, false
+	}
+
+	seconds, end := 0.0, 0
+	for _, loc := range parts {
+		// the parts have to follow each other without a gap
+		if loc[0] != end {
+			return 0, false
+		}
+		n, _ := strconv.ParseFloat(s[loc[2]:loc[3]], 64)
+		seconds += n * durationUnits[s[loc[4]:loc[5]]]
+		end = loc[1]
+	}
+	return seconds, end == len(s)
+}
+
+// the factors of the units of sizes in bytes, where
+// the ones with an i are powers of 1024
+var sizeUnits = map[string]float64{
+	"b":   1,
+	"k":   1e3,
+	"kb":  1e3,
+	"kib": 1 << 10,
+	"ki":  1 << 10,
+	"m":   1e6,
+	"mb":  1e6,
+	"mib": 1 << 20,
+	"mi":  1 << 20,
+	"g":   1e9,
+	"gb":  1e9,
+	"gib": 1 << 30,
+	"gi":  1 << 30,
+	"t":   1e12,
+	"tb":  1e12,
+	"tib": 1 << 40,
+	"ti":  1 << 40,
+	"p":   1e15,
+	"pb":  1e15,
+	"pib": 1 << 50,
+	"pi":  1 << 50,
+}
+
+var sizePattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([a-zA-Z]+)$`)
+
+// parseSize parses a size like 10MB, 1.5G or 512KiB into bytes.
+func parseSize(s string) (float64, bool) {
+	match := sizePattern.FindStringSubmatch(s)
+	if match == nil {
+		return 0, false
+	}
+	factor, ok := sizeUnits[strings.ToLower(match[2])]
+	if !ok {
+		return 0, false
+	}
+	n, _ := strconv.ParseFloat(match[1], 64)
+	return n * factor, true
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseWhere(t *testing.T) {
+	testOk := func(s string, field string, expected bool) {
+		p, err := parseWhere(s)
+		if err != nil {
+			t.Errorf("Expected (%t) Got error (%v) For (%s)", expected, err, s)
+			return
+		}
+		if actual := p.matches(field); actual != expected {
+			t.Errorf("Expected (%t) Got (%t) For (%s) With (%s)", expected, actual, s, field)
+		}
+	}
+	testFailed := func(s string) {
+		if _, err := parseWhere(s); err == nil {
+			t.Errorf("Expected error For (%s)", s)
+		}
+	}
+
+	testOk("3~^nginx", "nginx:latest", true)
+	testOk("3~^nginx", "my-nginx", false)
+	testOk("3!~^nginx", "my-nginx", true)
+	testOk("NAME=web", "web", true)
+	testOk("NAME=web", "web-1", false)
+	testOk("1!=", "", false)
+	testOk("1==", "=", true)
+
+	testOk("-1>100", "101", true)
+	testOk("-1>100", " 99 ", false)
+	testOk("-1<=1.5", "1.5", true)
+	testOk("-1>100MB", "1.5GB", true)
+	testOk("-1>100MB", "99 MB", false)
+	testOk("-1>=1KiB", "1024", true)
+	testOk("-1<1m", "30s", true)
+	testOk("-1<1m", "1h30m", false)
+	testOk("-1>1d", "2w", true)
+	testOk("-1>1", "abc", false)
+
+	// operators in sub-selections do not count
+	testOk("1[=]2=b", "b", true)
+
+	testFailed("")
+	testFailed("1")
+	testFailed("=1")
+	testFailed("1~(")
+	testFailed("1<abc")
+	testFailed("1:2:3:4=a")
+}
+
+func TestMatchesAll(t *testing.T) {
+	test := func(where []string, fields []string, expected bool) {
+		var predicates []predicate
+		for _, w := range where {
+			p, err := parseWhere(w)
+			if err != nil {
+				t.Errorf("Expected (%t) Got error (%v)", expected, err)
+				return
+			}
+			predicates = append(predicates, p)
+		}
+		if actual := matchesAll(predicates, recordOf(fields...)); actual != expected {
+			t.Errorf("Expected (%t) Got (%t) For (%v) With (%v)", expected, actual, where, fields)
+		}
+	}
+
+	fields := []string{"web", "nginx", "10MB"}
+	test(nil, fields, true)
+	test([]string{"2=nginx"}, fields, true)
+	test([]string{"2=nginx", "-1>1GB"}, fields, false)
+	test([]string{"-1=10MB"}, fields, true)
+	test([]string{"2:=10MB"}, fields, true)
+	test([]string{"5=x"}, fields, false)
+	test([]string{"5!=x"}, fields, false)
+}
+
+func TestParseDurationAndSize(t *testing.T) {
+	test := func(parse func(string) (float64, bool), s string, expected float64, ok bool) {
+		actual, actualOk := parse(s)
+		if actualOk != ok || (ok && actual != expected) {
+			t.Errorf("Expected (%v, %t) Got (%v, %t) For (%s)", expected, ok, actual, actualOk, s)
+		}
+	}
+
+	test(parseDuration, "1h30m", 5400, true)
+	test(parseDuration, "500ms", 0.5, true)
+	test(parseDuration, "1.5s", 1.5, true)
+	test(parseDuration, "1h 30m", 0, false)
+	test(parseDuration, "10MB", 0, false)
+	test(parseDuration, "s", 0, false)
+
+	test(parseSize, "10MB", 1e7, true)
+	test(parseSize, "1.5 G", 1.5e9, true)
+	test(parseSize, "2KiB", 2048, true)
+	test(parseSize, "1kb", 1000, true)
+	test(parseSize, "10", 0, false)
+	test(parseSize, "10XB", 0, false)
+}